@@ -0,0 +1,270 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/ringchain"
+	"go.uber.org/zap"
+)
+
+const DefaultMaxToolRounds = 8
+
+// Conversation is a chat session with an llm. Its history is kept in a Store so it survives restarts,
+// tool calls made by the llm are run automatically and old turns are compacted by the window policy.
+//
+// Sends on the same Conversation are serialized, different conversations can share a store and be used
+// concurrently. Two Conversation values must not be used with the same id at the same time.
+type Conversation struct {
+	id  string
+	llm openai.OpenAi
+
+	store         Store
+	systemPrompt  *string
+	tools         []ringchain.Tool
+	maxToolRounds int
+	window        WindowPolicy
+	promptOptions []func(*openai.ChatCompletionOptions)
+	logger        *zap.Logger
+
+	mu sync.Mutex
+}
+
+type Options struct {
+	store         Store
+	systemPrompt  *string
+	tools         []ringchain.Tool
+	maxToolRounds int
+	window        WindowPolicy
+	promptOptions []func(*openai.ChatCompletionOptions)
+	logger        *zap.Logger
+}
+
+// WithStore sets the store the history is kept in, it defaults to a new MemoryStore.
+func WithStore(store Store) func(*Options) {
+	return func(opts *Options) {
+		opts.store = store
+	}
+}
+
+// WithSystemPrompt sends the given system message before the history. It is not stored so it can change between sessions.
+func WithSystemPrompt(prompt string) func(*Options) {
+	return func(opts *Options) {
+		opts.systemPrompt = &prompt
+	}
+}
+
+// WithTools lets the llm call the given tools, their calls are run and answered automatically.
+func WithTools(tools ...ringchain.Tool) func(*Options) {
+	return func(opts *Options) {
+		opts.tools = append(opts.tools, tools...)
+	}
+}
+
+// WithMaxToolRounds sets how many times in a row the llm can call tools before Send fails, it defaults to DefaultMaxToolRounds.
+func WithMaxToolRounds(n int) func(*Options) {
+	return func(opts *Options) {
+		opts.maxToolRounds = n
+	}
+}
+
+// WithWindow compacts the history with the given policy before it is sent to the llm.
+func WithWindow(window WindowPolicy) func(*Options) {
+	return func(opts *Options) {
+		opts.window = window
+	}
+}
+
+// WithPromptOptions sets the chat completion options of every request.
+func WithPromptOptions(promptOptions ...func(*openai.ChatCompletionOptions)) func(*Options) {
+	return func(opts *Options) {
+		opts.promptOptions = append(opts.promptOptions, promptOptions...)
+	}
+}
+
+// WithLogger sets the logger passed to the tools, it defaults to a no-op logger.
+func WithLogger(logger *zap.Logger) func(*Options) {
+	return func(opts *Options) {
+		opts.logger = logger
+	}
+}
+
+func New(id string, llm openai.OpenAi, opts ...func(*Options)) *Conversation {
+	options := Options{
+		maxToolRounds: DefaultMaxToolRounds,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.store == nil {
+		options.store = NewMemoryStore()
+	}
+	if options.logger == nil {
+		options.logger = zap.NewNop()
+	}
+
+	return &Conversation{
+		id:            id,
+		llm:           llm,
+		store:         options.store,
+		systemPrompt:  options.systemPrompt,
+		tools:         options.tools,
+		maxToolRounds: options.maxToolRounds,
+		window:        options.window,
+		promptOptions: options.promptOptions,
+		logger:        options.logger,
+	}
+}
+
+func (c *Conversation) Id() string {
+	return c.id
+}
+
+// Reply is the result of a Send.
+type Reply struct {
+	// Content is the content of the final assistant message
+	Content string
+	// Messages are the messages the turn added to the history: the user message, the tool calls
+	// and their results, and the final assistant message
+	Messages []openai.Message
+	// Usage sums the usage of every request of the turn, including summaries made by the window policy
+	Usage openai.Usage
+}
+
+// Send sends a user message and returns the reply of the llm once every tool call has been answered.
+// The turn is only added to the history if it succeeds.
+func (c *Conversation) Send(ctx context.Context, content string) (Reply, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	history, err := c.store.Load(ctx, c.id)
+	if err != nil {
+		return Reply{}, fmt.Errorf("could not load history: %w", err)
+	}
+
+	reply := Reply{
+		Messages: []openai.Message{{Role: openai.UserRoleType, Content: openai.StrPtr(content)}},
+	}
+
+	if c.window != nil {
+		compacted, usage, err := c.window.Apply(ctx, history, reply.Messages)
+		reply.Usage = addUsage(reply.Usage, usage)
+		if err != nil {
+			return reply, fmt.Errorf("could not apply window: %w", err)
+		}
+		if compacted != nil {
+			if err := c.store.Replace(ctx, c.id, compacted); err != nil {
+				return reply, fmt.Errorf("could not replace history: %w", err)
+			}
+			history = compacted
+		}
+	}
+
+	opts := slices.Clone(c.promptOptions)
+	if len(c.tools) > 0 {
+		opts = append(opts, openai.WithTools(ringchain.OpenAiFunctions(c.tools)))
+	}
+
+	for round := 0; ; round++ {
+		msgs := make([]openai.Message, 0, len(history)+len(reply.Messages)+1)
+		if c.systemPrompt != nil {
+			msgs = append(msgs, openai.Message{Role: openai.SystemRoleType, Content: c.systemPrompt})
+		}
+		msgs = append(msgs, history...)
+		msgs = append(msgs, reply.Messages...)
+
+		resp, err := c.llm.ChatCompletionCreate(ctx, msgs, opts...)
+		if err != nil {
+			return reply, fmt.Errorf("failed to prompt openai: %w", err)
+		}
+		reply.Usage = addUsage(reply.Usage, resp.Usage)
+		if len(resp.Choices) == 0 {
+			return reply, fmt.Errorf("no choices returned")
+		}
+
+		choice := resp.Choices[0]
+		msg := openai.Message{
+			Role:      openai.AssistantRoleType,
+			Content:   choice.Message.Content,
+			ToolCalls: choice.Message.ToolCalls,
+		}
+		reply.Messages = append(reply.Messages, msg)
+
+		if len(msg.ToolCalls) == 0 {
+			if msg.Content == nil {
+				return reply, fmt.Errorf("no content in choice")
+			}
+			reply.Content = *msg.Content
+			break
+		}
+
+		if round >= c.maxToolRounds {
+			return reply, fmt.Errorf("%w: %d", ErrTooManyToolRounds, c.maxToolRounds)
+		}
+		for _, toolCall := range msg.ToolCalls {
+			reply.Messages = append(reply.Messages, openai.Message{
+				Role:       openai.ToolRoleType,
+				Content:    openai.StrPtr(c.runTool(ctx, toolCall)),
+				ToolCallId: toolCall.Id,
+			})
+		}
+	}
+
+	if err := c.store.Append(ctx, c.id, reply.Messages...); err != nil {
+		return reply, fmt.Errorf("could not append to history: %w", err)
+	}
+	return reply, nil
+}
+
+// runTool runs the tool called by the llm and returns its results as json. Failures are returned
+// to the llm as an error result so it can recover from them.
+func (c *Conversation) runTool(ctx context.Context, toolCall openai.ToolCall) string {
+	results, err := func() (map[string]any, error) {
+		tool, ok := ringchain.SelectTool(c.tools, toolCall.Function.Name)
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %q", toolCall.Function.Name)
+		}
+
+		args := make(map[string]any)
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		return tool.Run(ctx, c.logger, args)
+	}()
+	if err != nil {
+		c.logger.Warn("tool call failed", zap.String("tool", toolCall.Function.Name), zap.Error(err))
+		results = map[string]any{"error": err.Error()}
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Sprintf(`{"error": %q}`, err.Error())
+	}
+	return string(data)
+}
+
+// History returns the stored history of the conversation, without the system prompt.
+func (c *Conversation) History(ctx context.Context) ([]openai.Message, error) {
+	return c.store.Load(ctx, c.id)
+}
+
+// Reset deletes the history of the conversation.
+func (c *Conversation) Reset(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Delete(ctx, c.id)
+}
+
+func addUsage(a, b openai.Usage) openai.Usage {
+	return openai.Usage{
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
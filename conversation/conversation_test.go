@@ -0,0 +1,164 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// scriptedLLM returns its responses in order and records every request it receives.
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []openai.ChatCompletionMessage
+	requests  [][]openai.Message
+}
+
+func (l *scriptedLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests = append(l.requests, slices.Clone(messages))
+	if len(l.responses) == 0 {
+		return openai.ChatCompletionObject{}, fmt.Errorf("no response left")
+	}
+	msg := l.responses[0]
+	l.responses = l.responses[1:]
+	return openai.ChatCompletionObject{
+		Choices: []openai.Choice{{Message: msg}},
+		Usage:   openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func text(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.AssistantRoleType, Content: openai.StrPtr(content)}
+}
+
+func toolCall(id, name, args string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:      openai.AssistantRoleType,
+		ToolCalls: []openai.ToolCall{{Id: id, Type: openai.FunctionToolType, Function: openai.FunctionCall{Name: name, Arguments: args}}},
+	}
+}
+
+type weatherTool struct{}
+
+func (weatherTool) OpenAiTool() openai.Tool {
+	return openai.Tool{Type: openai.FunctionToolType, Function: openai.Function{Name: "get_weather"}}
+}
+func (weatherTool) FunctionName() string { return "get_weather" }
+func (weatherTool) Description() string  { return "Gets the weather of a city." }
+func (weatherTool) ToolName() string     { return "WeatherTool" }
+func (weatherTool) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	if args["city"] != "Paris" {
+		return nil, fmt.Errorf("unknown city %v", args["city"])
+	}
+	return map[string]any{"temperature": 21}, nil
+}
+
+func TestConversation_Send(t *testing.T) {
+	ctx := context.Background()
+	llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{text("Hi Alice!"), text("Your name is Alice.")}}
+	store := NewMemoryStore()
+	conv := New("alice", llm, WithStore(store), WithSystemPrompt("Be brief."))
+
+	reply, err := conv.Send(ctx, "Hi, I'm Alice.")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Alice!", reply.Content)
+	assert.Equal(t, 15, reply.Usage.TotalTokens)
+
+	reply, err = conv.Send(ctx, "What's my name?")
+	require.NoError(t, err)
+	assert.Equal(t, "Your name is Alice.", reply.Content)
+
+	// the system prompt is sent but not stored
+	require.Len(t, llm.requests, 2)
+	assert.Len(t, llm.requests[1], 4)
+	assert.Equal(t, openai.SystemRoleType, llm.requests[1][0].Role)
+	history, err := conv.History(ctx)
+	require.NoError(t, err)
+	assert.Len(t, history, 4)
+
+	// a new session with the same store picks the history up
+	llm.responses = []openai.ChatCompletionMessage{text("Still Alice.")}
+	_, err = New("alice", llm, WithStore(store)).Send(ctx, "And now?")
+	require.NoError(t, err)
+	assert.Len(t, llm.requests[2], 5)
+
+	require.NoError(t, conv.Reset(ctx))
+	history, err = conv.History(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestConversation_SendToolCalls(t *testing.T) {
+	ctx := context.Background()
+	llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{
+		toolCall("call_1", "get_weather", `{"city": "Paris"}`),
+		toolCall("call_2", "get_weather", `{"city": "Atlantis"}`),
+		text("It is 21°C in Paris."),
+	}}
+	conv := New("weather", llm, WithTools(weatherTool{}))
+
+	reply, err := conv.Send(ctx, "What's the weather in Paris and Atlantis?")
+	require.NoError(t, err)
+	assert.Equal(t, "It is 21°C in Paris.", reply.Content)
+	require.Len(t, reply.Messages, 6)
+	assert.Equal(t, "call_1", reply.Messages[2].ToolCallId)
+	assert.JSONEq(t, `{"temperature": 21}`, *reply.Messages[2].Content)
+	assert.JSONEq(t, `{"error": "unknown city Atlantis"}`, *reply.Messages[4].Content)
+	assert.Equal(t, 45, reply.Usage.TotalTokens)
+
+	history, err := conv.History(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, reply.Messages, history)
+}
+
+func TestConversation_SendTooManyToolRounds(t *testing.T) {
+	ctx := context.Background()
+	llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{
+		toolCall("call_1", "get_weather", `{"city": "Paris"}`),
+		toolCall("call_2", "get_weather", `{"city": "Paris"}`),
+	}}
+	conv := New("weather", llm, WithTools(weatherTool{}), WithMaxToolRounds(1))
+
+	_, err := conv.Send(ctx, "What's the weather in Paris?")
+	require.ErrorIs(t, err, ErrTooManyToolRounds)
+
+	// failed turns are not stored
+	history, err := conv.History(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestConversation_ConcurrentSessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{text("a"), text("b"), text("c")}}
+			conv := New(fmt.Sprintf("session-%d", i), llm, WithStore(store))
+			for range 3 {
+				_, err := conv.Send(ctx, "hi")
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range 8 {
+		history, err := store.Load(ctx, fmt.Sprintf("session-%d", i))
+		require.NoError(t, err)
+		assert.Len(t, history, 6)
+	}
+}
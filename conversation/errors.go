@@ -0,0 +1,5 @@
+package conversation
+
+import "errors"
+
+var ErrTooManyToolRounds = errors.New("too many tool call rounds")
@@ -0,0 +1,132 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// FileStore keeps every history in a JSON file named after the conversation id in a directory.
+// Files are written atomically so a crash never leaves a half written history.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore creates a store in dir, the directory is created if it doesn't exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// validIdRe keeps conversation ids from escaping the store directory.
+var validIdRe = regexp.MustCompile(`^[\w.-]+$`)
+
+func (s *FileStore) path(id string) (string, error) {
+	if !validIdRe.MatchString(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid conversation id %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileStore) Load(ctx context.Context, id string) ([]openai.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+func (s *FileStore) load(id string) ([]openai.Message, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []openai.Message{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var msgs []openai.Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal history %s: %w", id, err)
+	}
+	return msgs, nil
+}
+
+func (s *FileStore) Append(ctx context.Context, id string, msgs ...openai.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	return s.write(id, append(history, msgs...))
+}
+
+func (s *FileStore) Replace(ctx context.Context, id string, msgs []openai.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(id, msgs)
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) write(id string, msgs []openai.Message) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(msgs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal history %s: %w", id, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,171 @@
+package conversation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dskart/gollum/openai"
+)
+
+const DefaultTableName = "conversation_messages"
+
+// SQLStore keeps histories in a database/sql table, one row per message.
+// Call CreateTable once to create the table if it doesn't exist.
+type SQLStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(i int) string
+}
+
+var _ Store = (*SQLStore)(nil)
+
+type SQLStoreOptions struct {
+	table              string
+	numberedParameters bool
+}
+
+// WithTableName sets the table the messages are stored in, it defaults to DefaultTableName.
+func WithTableName(table string) func(*SQLStoreOptions) {
+	return func(opts *SQLStoreOptions) {
+		opts.table = table
+	}
+}
+
+// WithNumberedParameters uses $1, $2... query parameters instead of ?, for drivers like PostgreSQL's.
+func WithNumberedParameters() func(*SQLStoreOptions) {
+	return func(opts *SQLStoreOptions) {
+		opts.numberedParameters = true
+	}
+}
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][\w.]*$`)
+
+func NewSQLStore(db *sql.DB, opts ...func(*SQLStoreOptions)) (*SQLStore, error) {
+	options := SQLStoreOptions{
+		table: DefaultTableName,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	// the table name is written into the queries so it has to be a plain identifier
+	if !tableNameRe.MatchString(options.table) {
+		return nil, fmt.Errorf("invalid table name %q", options.table)
+	}
+
+	placeholder := func(int) string { return "?" }
+	if options.numberedParameters {
+		placeholder = func(i int) string { return fmt.Sprintf("$%d", i) }
+	}
+
+	return &SQLStore{
+		db:          db,
+		table:       options.table,
+		placeholder: placeholder,
+	}, nil
+}
+
+// CreateTable creates the messages table if it doesn't exist.
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	conversation_id VARCHAR(255) NOT NULL,
+	position INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	PRIMARY KEY (conversation_id, position)
+)`, s.table))
+	return err
+}
+
+// query replaces the ? parameters of the query with the placeholders of the driver.
+func (s *SQLStore) query(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString(s.placeholder(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return strings.ReplaceAll(sb.String(), "{table}", s.table)
+}
+
+func (s *SQLStore) Load(ctx context.Context, id string) ([]openai.Message, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT message FROM {table} WHERE conversation_id = ? ORDER BY position`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	msgs := make([]openai.Message, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg openai.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("could not unmarshal message of %s: %w", id, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (s *SQLStore) Append(ctx context.Context, id string, msgs ...openai.Message) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var position int
+		row := tx.QueryRowContext(ctx, s.query(`SELECT COALESCE(MAX(position) + 1, 0) FROM {table} WHERE conversation_id = ?`), id)
+		if err := row.Scan(&position); err != nil {
+			return err
+		}
+		return s.insert(ctx, tx, id, position, msgs)
+	})
+}
+
+func (s *SQLStore) Replace(ctx context.Context, id string, msgs []openai.Message) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM {table} WHERE conversation_id = ?`), id); err != nil {
+			return err
+		}
+		return s.insert(ctx, tx, id, 0, msgs)
+	})
+}
+
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`DELETE FROM {table} WHERE conversation_id = ?`), id)
+	return err
+}
+
+func (s *SQLStore) insert(ctx context.Context, tx *sql.Tx, id string, position int, msgs []openai.Message) error {
+	for i, msg := range msgs {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("could not marshal message of %s: %w", id, err)
+		}
+		_, err = tx.ExecContext(ctx, s.query(`INSERT INTO {table} (conversation_id, position, message) VALUES (?, ?, ?)`), id, position+i, string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package conversation
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// Store keeps the history of conversations. Implementations must be safe for concurrent use.
+type Store interface {
+	// Load should return the history of the conversation, or an empty history if it doesn't exist.
+	Load(ctx context.Context, id string) ([]openai.Message, error)
+
+	// Append should add the messages at the end of the history of the conversation, creating it if needed.
+	Append(ctx context.Context, id string, msgs ...openai.Message) error
+
+	// Replace should replace the whole history of the conversation, it is used when old turns are compacted.
+	Replace(ctx context.Context, id string, msgs []openai.Message) error
+
+	// Delete should delete the history of the conversation. Deleting a conversation that doesn't exist is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps histories in memory, they are lost when the process exits.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	histories map[string][]openai.Message
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		histories: make(map[string][]openai.Message),
+	}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, id string) ([]openai.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.histories[id]), nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, id string, msgs ...openai.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.histories[id] = append(s.histories[id], msgs...)
+	return nil
+}
+
+func (s *MemoryStore) Replace(ctx context.Context, id string, msgs []openai.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.histories[id] = slices.Clone(msgs)
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.histories, id)
+	return nil
+}
@@ -0,0 +1,183 @@
+package conversation
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+
+	stores := map[string]func(t *testing.T) Store{
+		"Memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+		"File": func(t *testing.T) Store {
+			store, err := NewFileStore(t.TempDir())
+			require.NoError(t, err)
+			return store
+		},
+		"SQL": func(t *testing.T) Store {
+			store, err := NewSQLStore(sql.OpenDB(&fakeConnector{db: &fakeDB{}}), WithNumberedParameters())
+			require.NoError(t, err)
+			require.NoError(t, store.CreateTable(ctx))
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			msgs, err := store.Load(ctx, "missing")
+			require.NoError(t, err)
+			assert.Empty(t, msgs)
+
+			user := openai.Message{Role: openai.UserRoleType, Content: openai.StrPtr("hi")}
+			toolCall := openai.Message{Role: openai.AssistantRoleType, ToolCalls: []openai.ToolCall{{Id: "call_1", Type: openai.FunctionToolType, Function: openai.FunctionCall{Name: "weather", Arguments: "{}"}}}}
+			tool := openai.Message{Role: openai.ToolRoleType, Content: openai.StrPtr(`{"temperature":21}`), ToolCallId: "call_1"}
+			require.NoError(t, store.Append(ctx, "a", user))
+			require.NoError(t, store.Append(ctx, "a", toolCall, tool))
+			require.NoError(t, store.Append(ctx, "b", user))
+
+			msgs, err = store.Load(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, []openai.Message{user, toolCall, tool}, msgs)
+
+			require.NoError(t, store.Replace(ctx, "a", []openai.Message{tool}))
+			msgs, err = store.Load(ctx, "a")
+			require.NoError(t, err)
+			assert.Equal(t, []openai.Message{tool}, msgs)
+
+			require.NoError(t, store.Delete(ctx, "a"))
+			require.NoError(t, store.Delete(ctx, "a"))
+			msgs, err = store.Load(ctx, "a")
+			require.NoError(t, err)
+			assert.Empty(t, msgs)
+
+			msgs, err = store.Load(ctx, "b")
+			require.NoError(t, err)
+			assert.Equal(t, []openai.Message{user}, msgs)
+		})
+	}
+}
+
+func TestFileStore_InvalidId(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	require.Error(t, store.Append(context.Background(), "../escape", openai.Message{}))
+}
+
+// fakeDB is a database/sql driver that only understands the queries of SQLStore.
+type fakeDB struct {
+	mu   sync.Mutex
+	rows []fakeRow
+}
+
+type fakeRow struct {
+	id       string
+	position int64
+	message  string
+}
+
+type fakeConnector struct {
+	db *fakeDB
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: c.db}, nil
+}
+func (c *fakeConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+	case strings.HasPrefix(s.query, "DELETE FROM"):
+		s.db.rows = slices.DeleteFunc(s.db.rows, func(r fakeRow) bool { return r.id == args[0] })
+	case strings.HasPrefix(s.query, "INSERT INTO"):
+		if !strings.Contains(s.query, "$3") {
+			return nil, errors.New("expected numbered parameters")
+		}
+		s.db.rows = append(s.db.rows, fakeRow{id: args[0].(string), position: args[1].(int64), message: args[2].(string)})
+	default:
+		return nil, errors.New("unexpected exec: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var values [][]driver.Value
+	switch {
+	case strings.HasPrefix(s.query, "SELECT message"):
+		rows := slices.Clone(s.db.rows)
+		slices.SortFunc(rows, func(a, b fakeRow) int { return int(a.position - b.position) })
+		for _, r := range rows {
+			if r.id == args[0] {
+				values = append(values, []driver.Value{r.message})
+			}
+		}
+	case strings.HasPrefix(s.query, "SELECT COALESCE"):
+		next := int64(0)
+		for _, r := range s.db.rows {
+			if r.id == args[0] {
+				next = max(next, r.position+1)
+			}
+		}
+		values = append(values, []driver.Value{next})
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return &fakeRows{values: values}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
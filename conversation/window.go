@@ -0,0 +1,182 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/scrolls"
+)
+
+// WindowPolicy keeps the history of a conversation inside the context limit of the llm.
+type WindowPolicy interface {
+	// Apply is called before a turn is sent with the stored history and the pending messages of the turn.
+	// It should return the compacted history to store instead, or nil if the history doesn't need to change.
+	// The pending messages count towards the limit but can't be compacted.
+	Apply(ctx context.Context, history []openai.Message, pending []openai.Message) ([]openai.Message, openai.Usage, error)
+}
+
+type WindowOptions struct {
+	tokenizer scrolls.Tokenizer
+	keepTurns int
+}
+
+// WithTokenizer sets the tokenizer used to count the tokens of the history, it defaults to scrolls.DefaultTokenizer.
+func WithTokenizer(tokenizer scrolls.Tokenizer) func(*WindowOptions) {
+	return func(opts *WindowOptions) {
+		opts.tokenizer = tokenizer
+	}
+}
+
+// WithKeepTurns sets the number of most recent turns that are never compacted, it defaults to 1.
+func WithKeepTurns(n int) func(*WindowOptions) {
+	return func(opts *WindowOptions) {
+		opts.keepTurns = n
+	}
+}
+
+func newWindowOptions(opts []func(*WindowOptions)) WindowOptions {
+	options := WindowOptions{
+		tokenizer: scrolls.DefaultTokenizer,
+		keepTurns: 1,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// DropWindow drops the oldest turns until the history fits in its max tokens.
+type DropWindow struct {
+	maxTokens int
+	options   WindowOptions
+}
+
+var _ WindowPolicy = (*DropWindow)(nil)
+
+func NewDropWindow(maxTokens int, opts ...func(*WindowOptions)) *DropWindow {
+	return &DropWindow{
+		maxTokens: maxTokens,
+		options:   newWindowOptions(opts),
+	}
+}
+
+func (w *DropWindow) Apply(ctx context.Context, history []openai.Message, pending []openai.Message) ([]openai.Message, openai.Usage, error) {
+	_, kept, ok := splitOldTurns(w.options, w.maxTokens, history, pending)
+	if !ok {
+		return nil, openai.Usage{}, nil
+	}
+	return kept, openai.Usage{}, nil
+}
+
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+const summaryPrompt = `Summarize the following conversation in a few sentences. Keep the facts, names, numbers and decisions the assistant will need to continue it.`
+
+// SummaryWindow replaces the oldest turns with a summary written by the llm when the history doesn't fit in its max tokens.
+// The summary is kept as a system message at the beginning of the history and is summarized again with the next old turns.
+type SummaryWindow struct {
+	llm       openai.OpenAi
+	maxTokens int
+	options   WindowOptions
+}
+
+var _ WindowPolicy = (*SummaryWindow)(nil)
+
+func NewSummaryWindow(llm openai.OpenAi, maxTokens int, opts ...func(*WindowOptions)) *SummaryWindow {
+	return &SummaryWindow{
+		llm:       llm,
+		maxTokens: maxTokens,
+		options:   newWindowOptions(opts),
+	}
+}
+
+func (w *SummaryWindow) Apply(ctx context.Context, history []openai.Message, pending []openai.Message) ([]openai.Message, openai.Usage, error) {
+	old, kept, ok := splitOldTurns(w.options, w.maxTokens, history, pending)
+	if !ok {
+		return nil, openai.Usage{}, nil
+	}
+
+	var transcript strings.Builder
+	for _, msg := range old {
+		switch {
+		case msg.Content == nil:
+			for _, toolCall := range msg.ToolCalls {
+				fmt.Fprintf(&transcript, "%s called %s(%s)\n", msg.Role, toolCall.Function.Name, toolCall.Function.Arguments)
+			}
+		case isSummary(msg):
+			fmt.Fprintf(&transcript, "%s\n", *msg.Content)
+		default:
+			fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, *msg.Content)
+		}
+	}
+
+	resp, err := w.llm.ChatCompletionCreate(ctx, []openai.Message{
+		{Role: openai.SystemRoleType, Content: openai.StrPtr(summaryPrompt)},
+		{Role: openai.UserRoleType, Content: openai.StrPtr(transcript.String())},
+	})
+	if err != nil {
+		return nil, openai.Usage{}, fmt.Errorf("could not summarize old turns: %w", err)
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == nil {
+		return nil, resp.Usage, fmt.Errorf("could not summarize old turns: no content returned")
+	}
+
+	summary := openai.Message{Role: openai.SystemRoleType, Content: openai.StrPtr(summaryPrefix + *resp.Choices[0].Message.Content)}
+	return append([]openai.Message{summary}, kept...), resp.Usage, nil
+}
+
+func isSummary(msg openai.Message) bool {
+	return msg.Role == openai.SystemRoleType && msg.Content != nil && strings.HasPrefix(*msg.Content, summaryPrefix)
+}
+
+// splitOldTurns splits the history into the oldest turns to compact and the turns to keep so that the kept
+// turns and the pending messages fit in maxTokens. It returns false if the history already fits or if there
+// is nothing that can be compacted. Turns start with a user message so tool calls are never split from their results.
+func splitOldTurns(options WindowOptions, maxTokens int, history []openai.Message, pending []openai.Message) ([]openai.Message, []openai.Message, bool) {
+	total := 0
+	for _, msg := range history {
+		total += scrolls.CountMessageTokens(options.tokenizer, msg)
+	}
+	for _, msg := range pending {
+		total += scrolls.CountMessageTokens(options.tokenizer, msg)
+	}
+	if total <= maxTokens {
+		return nil, nil, false
+	}
+
+	// history can be cut at the start of every turn but the first one, messages before the first
+	// user message like a previous summary belong to the first turn
+	cuts := make([]int, 0)
+	first := true
+	for i, msg := range history {
+		if msg.Role != openai.UserRoleType {
+			continue
+		}
+		if !first {
+			cuts = append(cuts, i)
+		}
+		first = false
+	}
+	cuts = append(cuts, len(history))
+
+	// the pending messages are the last turn, the turns before it are kept as well if needed
+	cuts = cuts[:max(0, len(cuts)-max(0, options.keepTurns-1))]
+
+	cut := 0
+	for _, next := range cuts {
+		if total <= maxTokens {
+			break
+		}
+		for _, msg := range history[cut:next] {
+			total -= scrolls.CountMessageTokens(options.tokenizer, msg)
+		}
+		cut = next
+	}
+
+	if cut == 0 {
+		return nil, nil, false
+	}
+	return history[:cut], history[cut:], true
+}
@@ -0,0 +1,83 @@
+package conversation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// turn returns a user message and its assistant reply of about 14 tokens each
+func turn(n string) []openai.Message {
+	return []openai.Message{
+		{Role: openai.UserRoleType, Content: openai.StrPtr(strings.Repeat(n, 40))},
+		{Role: openai.AssistantRoleType, Content: openai.StrPtr(strings.Repeat(n, 40))},
+	}
+}
+
+func history(turns ...string) []openai.Message {
+	ret := make([]openai.Message, 0)
+	for _, n := range turns {
+		ret = append(ret, turn(n)...)
+	}
+	return ret
+}
+
+func TestDropWindow(t *testing.T) {
+	ctx := context.Background()
+	pending := turn("p")[:1]
+
+	compacted, _, err := NewDropWindow(1000).Apply(ctx, history("a", "b"), pending)
+	require.NoError(t, err)
+	assert.Nil(t, compacted)
+
+	compacted, _, err = NewDropWindow(60).Apply(ctx, history("a", "b", "c"), pending)
+	require.NoError(t, err)
+	assert.Equal(t, history("c"), compacted)
+
+	compacted, _, err = NewDropWindow(10).Apply(ctx, history("a", "b", "c"), pending)
+	require.NoError(t, err)
+	assert.Empty(t, compacted)
+
+	compacted, _, err = NewDropWindow(10, WithKeepTurns(2)).Apply(ctx, history("a", "b", "c"), pending)
+	require.NoError(t, err)
+	assert.Equal(t, history("c"), compacted)
+}
+
+func TestSummaryWindow(t *testing.T) {
+	ctx := context.Background()
+	llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{text("They talked about a."), text("They talked about a and b.")}}
+	window := NewSummaryWindow(llm, 80)
+
+	compacted, usage, err := window.Apply(ctx, history("a", "b", "c"), turn("p")[:1])
+	require.NoError(t, err)
+	assert.Equal(t, 15, usage.TotalTokens)
+	require.Len(t, compacted, 5)
+	assert.Equal(t, summaryPrefix+"They talked about a.", *compacted[0].Content)
+	assert.Equal(t, history("b", "c"), compacted[1:])
+
+	// the previous summary is summarized again with the next old turn
+	compacted, _, err = window.Apply(ctx, append(compacted, turn("d")...), turn("p")[:1])
+	require.NoError(t, err)
+	assert.Equal(t, summaryPrefix+"They talked about a and b.", *compacted[0].Content)
+	assert.Contains(t, *llm.requests[1][1].Content, "They talked about a.")
+	assert.Equal(t, history("c", "d"), compacted[1:])
+}
+
+func TestConversation_SendWithWindow(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.NoError(t, store.Append(ctx, "long", history("a", "b", "c")...))
+
+	llm := &scriptedLLM{responses: []openai.ChatCompletionMessage{text("ok")}}
+	_, err := New("long", llm, WithStore(store), WithWindow(NewDropWindow(60))).Send(ctx, strings.Repeat("p", 40))
+	require.NoError(t, err)
+
+	stored, err := store.Load(ctx, "long")
+	require.NoError(t, err)
+	assert.Equal(t, history("c"), stored[:2])
+	assert.Len(t, stored, 4)
+}
@@ -2,7 +2,7 @@ package agent
 
 import (
 	"context"
-	"maps"
+	_ "embed"
 
 	"github.com/dskart/gollum/openai"
 	"github.com/dskart/gollum/ringchain"
@@ -10,48 +10,47 @@ import (
 )
 
 type SalesSummaryTool struct {
-	graph        *ringchain.Graph
-	lastNodeHash string
+	subgraph *ringchain.Subgraph
 }
 
-func NewSalesSummaryTool(llm openai.OpenAi) (*SalesSummaryTool, error) {
-	g := ringchain.NewGraph()
+//go:embed sales_summary_tool.yaml
+var salesSummaryToolSpec []byte
 
-	salesDataRetrievalNode, err := NewSalesDataRetrievalNode()
-	if err != nil {
+func NewSalesSummaryTool(llm openai.OpenAi) (*SalesSummaryTool, error) {
+	registry := ringchain.NewRegistry()
+	if err := registry.Register("sales_data_retrieval", func(config map[string]any) (ringchain.Node, error) {
+		return NewSalesDataRetrievalNode()
+	}); err != nil {
 		return nil, err
 	}
-
-	salesDataSumNode, err := NewSalesDataSumNode()
-	if err != nil {
+	if err := registry.Register("sales_data_sum", func(config map[string]any) (ringchain.Node, error) {
+		return NewSalesDataSumNode()
+	}); err != nil {
 		return nil, err
 	}
-
-	salesTotalSummarizerNode, err := NewSalesSummarizerNode(llm)
-	if err != nil {
+	if err := registry.Register("sales_summarizer", func(config map[string]any) (ringchain.Node, error) {
+		return NewSalesSummarizerNode(llm)
+	}); err != nil {
 		return nil, err
 	}
 
-	if err := g.AddNode(salesDataRetrievalNode.Name(), salesDataRetrievalNode); err != nil {
-		return nil, err
-	}
-	if err := g.AddNode(salesDataSumNode.Name(), salesDataSumNode); err != nil {
+	spec, err := ringchain.ParseGraphSpec(salesSummaryToolSpec)
+	if err != nil {
 		return nil, err
 	}
-	if err := g.AddNode(salesTotalSummarizerNode.Name(), salesTotalSummarizerNode); err != nil {
+	g, err := ringchain.NewGraphFromSpec(spec, registry)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := g.AddEdge(salesDataRetrievalNode.Name(), salesDataSumNode.Name()); err != nil {
-		return nil, err
-	}
-	if err := g.AddEdge(salesDataSumNode.Name(), salesTotalSummarizerNode.Name()); err != nil {
+	// the summarizer is the only sink, so its result is the result of the tool
+	subgraph, err := ringchain.NewSubgraph("SalesSummaryTool", g)
+	if err != nil {
 		return nil, err
 	}
 
 	return &SalesSummaryTool{
-		graph:        g,
-		lastNodeHash: salesTotalSummarizerNode.Name(),
+		subgraph: subgraph,
 	}, nil
 }
 
@@ -100,11 +99,5 @@ func (n *SalesSummaryTool) Description() string {
 }
 
 func (n *SalesSummaryTool) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
-	results := make(map[string]any)
-	res, err := n.graph.Execute(ctx, logger, args)
-	if err != nil {
-		return nil, err
-	}
-	maps.Copy(results, res[n.lastNodeHash])
-	return results, nil
+	return n.subgraph.Run(ctx, logger, args)
 }
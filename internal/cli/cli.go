@@ -0,0 +1,191 @@
+// Package cli implements the gollum command line tool.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/scrolls"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `Usage: gollum <command> [flags] <scroll>
+
+Commands:
+  render    render a scroll and print its messages
+  validate  check that scrolls parse and their gen actions are valid
+  run       execute a scroll and print its outputs and token usage
+
+Run 'gollum <command> -h' for the flags of a command.
+`
+
+type command struct {
+	name string
+	run  func(ctx context.Context, args []string, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "render", run: render},
+	{name: "validate", run: validate},
+	{name: "run", run: run},
+}
+
+// Run runs the gollum command line with the given arguments, without the program name, and returns its exit code.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(ctx, args[1:], stdout, stderr)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			fmt.Fprintf(stderr, "gollum %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "gollum: unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+// errUsage is returned when a command is called with invalid flags or arguments, the usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+// scrollFlags are the flags shared by every command to load a scroll and its args.
+type scrollFlags struct {
+	argsFile string
+	base     string
+	partials stringsFlag
+}
+
+func (f *scrollFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.argsFile, "args", "", "JSON or YAML `file` with the scroll args")
+	fs.StringVar(&f.base, "base", "", "base scroll `file` the scroll extends")
+	fs.Var(&f.partials, "partials", "glob `pattern` of partial files, can be repeated")
+}
+
+func (f *scrollFlags) scrollOptions() ([]func(*scrolls.Options), error) {
+	opts := make([]func(*scrolls.Options), 0)
+
+	if f.base != "" {
+		data, err := os.ReadFile(f.base)
+		if err != nil {
+			return nil, fmt.Errorf("could not read base scroll: %w", err)
+		}
+		opts = append(opts, scrolls.WithBase(string(data)))
+	}
+
+	for _, pattern := range f.partials {
+		partials, err := scrolls.LoadPartials(os.DirFS(filepath.Dir(pattern)), filepath.Base(pattern))
+		if err != nil {
+			return nil, err
+		}
+		if len(partials) == 0 {
+			return nil, fmt.Errorf("no partials match %q", pattern)
+		}
+		opts = append(opts, scrolls.WithPartials(partials))
+	}
+
+	return opts, nil
+}
+
+func (f *scrollFlags) loadScroll(path string, llm openai.OpenAi) (*scrolls.Scroll, error) {
+	opts, err := f.scrollOptions()
+	if err != nil {
+		return nil, err
+	}
+	return scrolls.NewFromFile(os.DirFS(filepath.Dir(path)), filepath.Base(path), llm, opts...)
+}
+
+func (f *scrollFlags) loadArgs() (map[string]any, error) {
+	if f.argsFile == "" {
+		return map[string]any{}, nil
+	}
+	return readArgs(f.argsFile)
+}
+
+// readArgs reads scroll args from a JSON file, or a YAML file if it has a .yaml or .yml extension.
+func readArgs(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read args: %w", err)
+	}
+
+	args := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &args)
+	default:
+		err = json.Unmarshal(data, &args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse args %s: %w", path, err)
+	}
+	return args, nil
+}
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func newFlagSet(name string, stderr io.Writer, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gollum %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the command flags and checks it got exactly one positional argument, or at least one if many is set.
+func parseFlags(fs *flag.FlagSet, args []string, many bool) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() == 0 || (!many && fs.NArg() > 1) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
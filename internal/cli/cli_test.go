@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScroll = `[[#system~]]
+You are a helpful assistant.
+[[~/system]]
+[[#user~]]
+{{.question}}
+[[~/user]]
+[[#assistant~]]
+{"action": "gen", "output_name": "answer"}
+[[~/assistant]]
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func runCli(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	scroll := writeFile(t, dir, "qa.scroll", testScroll)
+
+	for _, argsFile := range []string{
+		writeFile(t, dir, "args.json", `{"question": "What is 1+1?"}`),
+		writeFile(t, dir, "args.yaml", "question: What is 1+1?\n"),
+	} {
+		t.Run(filepath.Ext(argsFile), func(t *testing.T) {
+			code, stdout, stderr := runCli(t, "render", "-args", argsFile, scroll)
+			require.Equal(t, 0, code, stderr)
+
+			var msgs []openai.Message
+			require.NoError(t, json.Unmarshal([]byte(stdout), &msgs))
+			require.Len(t, msgs, 3)
+			assert.Equal(t, "What is 1+1?", *msgs[1].Content)
+		})
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		code, stdout, stderr := runCli(t, "render", "-format", "text", scroll)
+		require.Equal(t, 0, code, stderr)
+		assert.Contains(t, stdout, "system:\nYou are a helpful assistant.\n")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.scroll", testScroll)
+	invalid := writeFile(t, dir, "invalid.scroll", `[[#assistant~]]{"action": "gen"}[[~/assistant]]`)
+
+	code, stdout, _ := runCli(t, "validate", valid)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "ok   "+valid)
+
+	code, stdout, stderr := runCli(t, "validate", valid, invalid)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "FAIL "+invalid)
+	assert.Contains(t, stderr, "1 of 2 scrolls are invalid")
+}
+
+func TestRunDryRun(t *testing.T) {
+	dir := t.TempDir()
+	scroll := writeFile(t, dir, "qa.scroll", testScroll)
+	args := writeFile(t, dir, "args.json", `{"question": "What is 1+1?"}`)
+
+	t.Run("Placeholder", func(t *testing.T) {
+		code, stdout, stderr := runCli(t, "run", "-dry-run", "-args", args, scroll)
+		require.Equal(t, 0, code, stderr)
+		assert.Contains(t, stdout, "answer: <dry run response to 2 messages>\n")
+		assert.Contains(t, stdout, "usage: 0 prompt + 0 completion = 0 tokens\n")
+	})
+
+	t.Run("Responses", func(t *testing.T) {
+		responses := writeFile(t, dir, "responses.json", `["2"]`)
+		code, stdout, stderr := runCli(t, "run", "-dry-run", "-responses", responses, "-json", "-args", args, scroll)
+		require.Equal(t, 0, code, stderr)
+
+		var res runResult
+		require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+		assert.Equal(t, map[string]any{"answer": "2"}, res.Outputs)
+	})
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runCli(t)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage: gollum")
+
+	code, _, stderr = runCli(t, "explode")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "explode"`)
+
+	code, _, _ = runCli(t, "render")
+	assert.Equal(t, 2, code)
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// dryRunLLM returns the responses read from responsesFile in order, or a placeholder response for every gen if there is no file.
+func dryRunLLM(responsesFile string) (openai.OpenAi, error) {
+	if responsesFile == "" {
+		return placeholderLLM{}, nil
+	}
+
+	data, err := os.ReadFile(responsesFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read responses: %w", err)
+	}
+	var responses []string
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("could not parse responses %s: %w", responsesFile, err)
+	}
+	return &scriptedLLM{responses: responses}, nil
+}
+
+// scriptedLLM answers every prompt with the next of its responses.
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []string
+	requests  int
+}
+
+func (l *scriptedLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests++
+	if len(l.responses) == 0 {
+		return openai.ChatCompletionObject{}, fmt.Errorf("dry run: no response left for request %d", l.requests)
+	}
+	content := l.responses[0]
+	l.responses = l.responses[1:]
+	return completion(content), nil
+}
+
+// placeholderLLM answers every prompt with a placeholder that shows how many messages it was sent.
+type placeholderLLM struct{}
+
+func (placeholderLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	return completion(fmt.Sprintf("<dry run response to %d messages>", len(messages))), nil
+}
+
+func completion(content string) openai.ChatCompletionObject {
+	return openai.ChatCompletionObject{
+		Object: "chat.completion",
+		Choices: []openai.Choice{
+			{
+				FinishReason: openai.StopFinishReasonType,
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.AssistantRoleType,
+					Content: &content,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dskart/gollum/openai"
+)
+
+func render(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var flags scrollFlags
+	fs := newFlagSet("render", stderr, "<scroll>")
+	flags.register(fs)
+	format := fs.String("format", "json", "output `format`, json or text")
+	if err := parseFlags(fs, args, false); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "text" {
+		fmt.Fprintf(stderr, "invalid format %q\n", *format)
+		fs.Usage()
+		return errUsage
+	}
+
+	scrollArgs, err := flags.loadArgs()
+	if err != nil {
+		return err
+	}
+	scroll, err := flags.loadScroll(fs.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+
+	msgs, truncations, err := scroll.Render(scrollArgs)
+	if err != nil {
+		return err
+	}
+	for _, truncation := range truncations {
+		fmt.Fprintf(stderr, "truncated %s from %d to %d tokens\n", truncation.Target, truncation.OriginalTokens, truncation.Tokens)
+	}
+
+	if *format == "text" {
+		return writeMessages(stdout, msgs)
+	}
+	return writeJSON(stdout, msgs)
+}
+
+func writeMessages(w io.Writer, msgs []openai.Message) error {
+	for i, msg := range msgs {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		header := string(msg.Role)
+		if msg.Name != nil {
+			header += " (" + *msg.Name + ")"
+		}
+		if msg.ToolCallId != "" {
+			header += " [" + msg.ToolCallId + "]"
+		}
+		if _, err := fmt.Fprintf(w, "%s:\n", header); err != nil {
+			return err
+		}
+
+		if msg.Content != nil {
+			if _, err := fmt.Fprintln(w, *msg.Content); err != nil {
+				return err
+			}
+		}
+		for _, toolCall := range msg.ToolCalls {
+			if _, err := fmt.Fprintf(w, "-> %s [%s] %s\n", toolCall.Function.Name, toolCall.Id, toolCall.Function.Arguments); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/scrolls"
+)
+
+// runResult is what the run command prints with -json.
+type runResult struct {
+	Outputs     map[string]any       `json:"outputs"`
+	Usage       openai.Usage         `json:"usage"`
+	Truncations []scrolls.Truncation `json:"truncations,omitempty"`
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var flags scrollFlags
+	fs := newFlagSet("run", stderr, "<scroll>")
+	flags.register(fs)
+	model := fs.String("model", os.Getenv("GPT_MODEL"), "`model` to prompt, defaults to $GPT_MODEL")
+	rawUrl := fs.String("url", "", "`url` of an OpenAI compatible endpoint, defaults to the OpenAI API")
+	dryRun := fs.Bool("dry-run", false, "run against a fake llm instead of the endpoint")
+	responsesFile := fs.String("responses", "", "JSON `file` with the list of responses the fake llm returns in dry run mode")
+	stream := fs.Bool("stream", false, "stream the generated tokens to stderr")
+	jsonOutput := fs.Bool("json", false, "print the outputs, usage and truncations as JSON")
+	if err := parseFlags(fs, args, false); err != nil {
+		return err
+	}
+
+	scrollArgs, err := flags.loadArgs()
+	if err != nil {
+		return err
+	}
+
+	var llm openai.OpenAi
+	if *dryRun {
+		llm, err = dryRunLLM(*responsesFile)
+	} else {
+		opts := make([]func(*openai.OpenAiOptions), 0)
+		if *rawUrl != "" {
+			opts = append(opts, openai.WithUrl(*rawUrl))
+		}
+		llm, err = openai.New(openai.Config{
+			OpenAiKey: os.Getenv("OPENAI_API_KEY"),
+			GptModel:  *model,
+		}, opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	scroll, err := flags.loadScroll(fs.Arg(0), llm)
+	if err != nil {
+		return err
+	}
+
+	// the usage and truncations are collected from the execution events
+	var mu sync.Mutex
+	res := runResult{Truncations: make([]scrolls.Truncation, 0)}
+	handler := func(event scrolls.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Type {
+		case scrolls.TruncatedEventType:
+			res.Truncations = append(res.Truncations, *event.Truncation)
+		case scrolls.TokenDeltaEventType:
+			if *stream {
+				fmt.Fprint(stderr, event.Delta)
+			}
+		case scrolls.GenFinishedEventType:
+			if *stream {
+				fmt.Fprintln(stderr)
+			}
+			if event.Usage != nil {
+				res.Usage.PromptTokens += event.Usage.PromptTokens
+				res.Usage.CompletionTokens += event.Usage.CompletionTokens
+				res.Usage.TotalTokens += event.Usage.TotalTokens
+			}
+		}
+	}
+
+	_, outputs, err := scroll.ExecuteParsed(ctx, scrollArgs, scrolls.WithEventHandler(handler))
+	if err != nil {
+		return err
+	}
+	res.Outputs = outputs
+
+	if *jsonOutput {
+		return writeJSON(stdout, res)
+	}
+	return writeOutputs(stdout, res)
+}
+
+func writeOutputs(w io.Writer, res runResult) error {
+	for _, name := range slices.Sorted(maps.Keys(res.Outputs)) {
+		value, ok := res.Outputs[name].(string)
+		if !ok {
+			data, err := json.Marshal(res.Outputs[name])
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", name, value); err != nil {
+			return err
+		}
+	}
+
+	for _, truncation := range res.Truncations {
+		if _, err := fmt.Fprintf(w, "truncated %s from %d to %d tokens\n", truncation.Target, truncation.OriginalTokens, truncation.Tokens); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "usage: %d prompt + %d completion = %d tokens\n", res.Usage.PromptTokens, res.Usage.CompletionTokens, res.Usage.TotalTokens)
+	return err
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+func validate(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var flags scrollFlags
+	fs := newFlagSet("validate", stderr, "<scroll>...")
+	flags.register(fs)
+	if err := parseFlags(fs, args, true); err != nil {
+		return err
+	}
+
+	scrollArgs, err := flags.loadArgs()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		scroll, err := flags.loadScroll(path, nil)
+		if err == nil {
+			err = scroll.Validate(scrollArgs)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(stdout, "FAIL %s: %v\n", path, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "ok   %s\n", path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scrolls are invalid", failed, fs.NArg())
+	}
+	return nil
+}
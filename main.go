@@ -0,0 +1,17 @@
+// Command gollum renders, validates and runs scroll files from the command line.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/dskart/gollum/internal/cli"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
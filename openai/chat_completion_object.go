@@ -15,6 +15,38 @@ type Choice struct {
 	FinishReason FinishReasonType      `json:"finish_reason"`
 	Index        int                   `json:"index"`
 	Message      ChatCompletionMessage `json:"message"`
+	Logprobs     *Logprobs             `json:"logprobs,omitempty"`
+}
+
+// Logprobs holds the log probabilities of the tokens of a choice, it is only set when requested with WithLogprobs.
+type Logprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes,omitempty"`
+	TopLogprobs []TopLogprob `json:"top_logprobs,omitempty"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes,omitempty"`
+}
+
+// MeanLogprob returns the mean log probability of the tokens of the choice, it returns false if the choice has no logprobs.
+func (c Choice) MeanLogprob() (float64, bool) {
+	if c.Logprobs == nil || len(c.Logprobs.Content) == 0 {
+		return 0, false
+	}
+
+	sum := 0.0
+	for _, token := range c.Logprobs.Content {
+		sum += token.Logprob
+	}
+	return sum / float64(len(c.Logprobs.Content)), true
 }
 
 type FinishReasonType string
@@ -5,16 +5,20 @@ import "context"
 type ChatCompletionOptions struct {
 	frequencyPenalty *float64
 	logitBias        *map[string]float64
+	logprobs         *bool
 	maxToken         *int
+	model            *string
 	n                *int
 	presencyPenalty  *float64
 	responseFormat   *ResponseFormat
+	seed             *int
 	stop             *[]string
 	stream           *bool
 	temperature      *float64
 	topP             *float64
 	tools            *[]Tool
 	toolChoice       *string
+	topLogprobs      *int
 	user             *string
 }
 
@@ -30,12 +34,26 @@ func WithLogitBias(logitBias map[string]float64) func(*ChatCompletionOptions) {
 	}
 }
 
+// WithLogprobs makes the api return the log probability of every generated token.
+func WithLogprobs(logprobs bool) func(*ChatCompletionOptions) {
+	return func(opts *ChatCompletionOptions) {
+		opts.logprobs = &logprobs
+	}
+}
+
 func WithMaxToken(maxToken int) func(*ChatCompletionOptions) {
 	return func(opts *ChatCompletionOptions) {
 		opts.maxToken = &maxToken
 	}
 }
 
+// WithModel overrides the gpt model of the client for a single request.
+func WithModel(model string) func(*ChatCompletionOptions) {
+	return func(opts *ChatCompletionOptions) {
+		opts.model = &model
+	}
+}
+
 func WithN(n int) func(*ChatCompletionOptions) {
 	return func(opts *ChatCompletionOptions) {
 		opts.n = &n
@@ -54,6 +72,12 @@ func WithResponseFormat(responseFormat ResponseFormat) func(*ChatCompletionOptio
 	}
 }
 
+func WithSeed(seed int) func(*ChatCompletionOptions) {
+	return func(opts *ChatCompletionOptions) {
+		opts.seed = &seed
+	}
+}
+
 func WithStop(stop []string) func(*ChatCompletionOptions) {
 	return func(opts *ChatCompletionOptions) {
 		opts.stop = &stop
@@ -90,6 +114,13 @@ func WithToolChoice(toolChoice string) func(*ChatCompletionOptions) {
 	}
 }
 
+// WithTopLogprobs makes the api return the n most likely tokens at each position, it requires WithLogprobs.
+func WithTopLogprobs(n int) func(*ChatCompletionOptions) {
+	return func(opts *ChatCompletionOptions) {
+		opts.topLogprobs = &n
+	}
+}
+
 func WithUser(user string) func(*ChatCompletionOptions) {
 	return func(opts *ChatCompletionOptions) {
 		opts.user = &user
@@ -97,35 +128,47 @@ func WithUser(user string) func(*ChatCompletionOptions) {
 }
 
 func (o *OpenAiImpl) ChatCompletionCreate(ctx context.Context, messages []Message, opts ...func(*ChatCompletionOptions)) (ChatCompletionObject, error) {
+	reqBody := o.chatCompletionRequestBody(messages, opts...)
+
+	resp, err := request[ChatCompletionRequestBody, ChatCompletionObject](ctx, o.httpClient, o.getChatCompletionUrl(), o.apiKey, reqBody)
+	if err != nil {
+		return ChatCompletionObject{}, err
+	}
+
+	return *resp, nil
+}
+
+func (o *OpenAiImpl) chatCompletionRequestBody(messages []Message, opts ...func(*ChatCompletionOptions)) ChatCompletionRequestBody {
 	options := ChatCompletionOptions{}
 	for _, o := range opts {
 		o(&options)
 	}
 
-	reqBody := ChatCompletionRequestBody{
+	model := o.gptModel
+	if options.model != nil {
+		model = *options.model
+	}
+
+	return ChatCompletionRequestBody{
 		Messages:         messages,
-		Model:            o.gptModel,
+		Model:            model,
 		FrequencyPenalty: options.frequencyPenalty,
 		LogitBias:        options.logitBias,
+		Logprobs:         options.logprobs,
 		MaxToken:         options.maxToken,
 		N:                options.n,
 		PresencyPenalty:  options.presencyPenalty,
 		ResponseFormat:   options.responseFormat,
+		Seed:             options.seed,
 		Stop:             options.stop,
 		Stream:           options.stream,
 		Temperature:      options.temperature,
 		TopP:             options.topP,
 		Tools:            options.tools,
 		ToolChoice:       options.toolChoice,
+		TopLogprobs:      options.topLogprobs,
 		User:             options.user,
 	}
-
-	resp, err := request[ChatCompletionRequestBody, ChatCompletionObject](ctx, o.httpClient, o.getChatCompletionUrl(), o.apiKey, reqBody)
-	if err != nil {
-		return ChatCompletionObject{}, err
-	}
-
-	return *resp, nil
 }
 
 // https://platform.openai.com/docs/api-reference/chat/create
@@ -134,6 +177,7 @@ type ChatCompletionRequestBody struct {
 	Model            string              `json:"model"`
 	FrequencyPenalty *float64            `json:"frequency_penalty,omitempty"`
 	LogitBias        *map[string]float64 `json:"logit_bias,omitempty"`
+	Logprobs         *bool               `json:"logprobs,omitempty"`
 	MaxToken         *int                `json:"max_tokens,omitempty"`
 	N                *int                `json:"n,omitempty"`
 	PresencyPenalty  *float64            `json:"presence_penalty,omitempty"`
@@ -141,10 +185,12 @@ type ChatCompletionRequestBody struct {
 	Seed             *int                `json:"seed,omitempty"`
 	Stop             *[]string           `json:"stop,omitempty"`
 	Stream           *bool               `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions      `json:"stream_options,omitempty"`
 	Temperature      *float64            `json:"temperature,omitempty"`
 	TopP             *float64            `json:"top_p,omitempty"`
 	Tools            *[]Tool             `json:"tools,omitempty"`
 	ToolChoice       *string             `json:"tool_choice,omitempty"`
+	TopLogprobs      *int                `json:"top_logprobs,omitempty"`
 	User             *string             `json:"user,omitempty"`
 }
 
@@ -98,16 +98,25 @@ func TestChatCompletionCreateWithOptions(t *testing.T) {
 		options := ChatCompletionOptions{}
 		opt(&options)
 
+		model := TEST_MODEL
+		if options.model != nil {
+			model = *options.model
+		}
+
 		return ChatCompletionRequestBody{
 			Messages:         msgs,
-			Model:            TEST_MODEL,
+			Model:            model,
 			FrequencyPenalty: options.frequencyPenalty,
 			Tools:            options.tools,
 			ToolChoice:       options.toolChoice,
 			LogitBias:        options.logitBias,
+			Logprobs:         options.logprobs,
+			TopLogprobs:      options.topLogprobs,
 			MaxToken:         options.maxToken,
 			N:                options.n,
 			PresencyPenalty:  options.presencyPenalty,
+			ResponseFormat:   options.responseFormat,
+			Seed:             options.seed,
 			Stop:             options.stop,
 			Temperature:      options.temperature,
 			TopP:             options.topP,
@@ -123,9 +132,14 @@ func TestChatCompletionCreateWithOptions(t *testing.T) {
 		{fieldName: "Tools", option: WithTools([]Tool{{Type: FunctionToolType, Function: Function{Name: "foo"}}})},
 		{fieldName: "ToolChoice", option: WithToolChoice("foo")},
 		{fieldName: "LogitBias", option: WithLogitBias(map[string]float64{"foo": 0.5})},
+		{fieldName: "Logprobs", option: WithLogprobs(true)},
+		{fieldName: "TopLogprobs", option: WithTopLogprobs(3)},
 		{fieldName: "MaxToken", option: WithMaxToken(10)},
+		{fieldName: "Model", option: WithModel("gpt-4o-mini")},
 		{fieldName: "N", option: WithN(10)},
 		{fieldName: "PresencyPenalty", option: WithPresencyPenalty(0.5)},
+		{fieldName: "ResponseFormat", option: WithResponseFormat(ResponseFormat{Type: JsonObjectResponseFormatType})},
+		{fieldName: "Seed", option: WithSeed(42)},
 		{fieldName: "Stop", option: WithStop([]string{"foo"})},
 		{fieldName: "Temperature", option: WithTemperature(0.5)},
 		{fieldName: "TopP", option: WithTopP(0.5)},
@@ -0,0 +1,160 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// OpenAiStreamer is implemented by clients that can stream chat completions chunk by chunk.
+type OpenAiStreamer interface {
+	ChatCompletionStream(ctx context.Context, messages []Message, onChunk func(ChatCompletionChunk) error, opts ...func(*ChatCompletionOptions)) (ChatCompletionObject, error)
+}
+
+// https://platform.openai.com/docs/api-reference/chat/streaming
+type ChatCompletionChunk struct {
+	Id                string        `json:"id"`
+	Choices           []ChunkChoice `json:"choices"`
+	Created           int64         `json:"created"`
+	Model             string        `json:"model"`
+	SystemFingerprint string        `json:"system_fingerprint"`
+	Object            string        `json:"object"`
+	Usage             *Usage        `json:"usage,omitempty"`
+}
+
+type ChunkChoice struct {
+	Delta        ChunkDelta        `json:"delta"`
+	FinishReason *FinishReasonType `json:"finish_reason"`
+	Index        int               `json:"index"`
+	Logprobs     *Logprobs         `json:"logprobs,omitempty"`
+}
+
+type ChunkDelta struct {
+	Content *string  `json:"content,omitempty"`
+	Role    RoleType `json:"role,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionStream creates a streamed chat completion, onChunk is called for every chunk received.
+// The chunks are accumulated and returned as a single ChatCompletionObject once the stream is done.
+func (o *OpenAiImpl) ChatCompletionStream(ctx context.Context, messages []Message, onChunk func(ChatCompletionChunk) error, opts ...func(*ChatCompletionOptions)) (ChatCompletionObject, error) {
+	opts = append(opts, WithStream(true))
+	reqBody := o.chatCompletionRequestBody(messages, opts...)
+	reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	body, err := stream(ctx, o.httpClient, o.getChatCompletionUrl(), o.apiKey, reqBody)
+	if err != nil {
+		return ChatCompletionObject{}, err
+	}
+	defer func() {
+		err := body.Close()
+		if err != nil {
+			fmt.Printf("Failed to close response body: %v", err)
+		}
+	}()
+
+	resp := ChatCompletionObject{}
+	contents := make(map[int]*strings.Builder)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			break
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return ChatCompletionObject{}, fmt.Errorf("could not unmarshal chunk: %w", err)
+		}
+
+		resp.Id = chunk.Id
+		resp.Created = chunk.Created
+		resp.Model = chunk.Model
+		resp.SystemFingerprint = chunk.SystemFingerprint
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			for len(resp.Choices) <= c.Index {
+				resp.Choices = append(resp.Choices, Choice{Index: len(resp.Choices), Message: ChatCompletionMessage{Role: AssistantRoleType}})
+			}
+			if c.Delta.Content != nil {
+				if _, ok := contents[c.Index]; !ok {
+					contents[c.Index] = &strings.Builder{}
+				}
+				contents[c.Index].WriteString(*c.Delta.Content)
+			}
+			if c.FinishReason != nil {
+				resp.Choices[c.Index].FinishReason = *c.FinishReason
+			}
+			if c.Logprobs != nil {
+				if resp.Choices[c.Index].Logprobs == nil {
+					resp.Choices[c.Index].Logprobs = &Logprobs{}
+				}
+				resp.Choices[c.Index].Logprobs.Content = append(resp.Choices[c.Index].Logprobs.Content, c.Logprobs.Content...)
+			}
+		}
+
+		if err := onChunk(chunk); err != nil {
+			return ChatCompletionObject{}, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ChatCompletionObject{}, err
+	}
+
+	resp.Object = "chat.completion"
+	for i, content := range contents {
+		c := content.String()
+		resp.Choices[i].Message.Content = &c
+	}
+
+	return resp, nil
+}
+
+func stream(ctx context.Context, httpClient *retryablehttp.Client, url string, apiKey string, reqBody ChatCompletionRequestBody) (io.ReadCloser, error) {
+	rawBody, err := json.Marshal(&reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, url, rawBody)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("Accept", "text/event-stream")
+	r.Header.Add("Authorization", "Bearer "+apiKey)
+
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		respData, _ := io.ReadAll(resp.Body)
+		defer func() {
+			err := resp.Body.Close()
+			if err != nil {
+				fmt.Printf("Failed to close response body: %v", err)
+			}
+		}()
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(respData))
+	}
+
+	return resp.Body, nil
+}
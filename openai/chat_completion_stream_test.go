@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatCompletionStream(t *testing.T) {
+	ctx := context.Background()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqData, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var reqBody ChatCompletionRequestBody
+		require.NoError(t, json.Unmarshal(reqData, &reqBody))
+		require.NotNil(t, reqBody.Stream)
+		assert.True(t, *reqBody.Stream)
+		require.NotNil(t, reqBody.StreamOptions)
+		assert.True(t, reqBody.StreamOptions.IncludeUsage)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		stop := StopFinishReasonType
+		chunks := []ChatCompletionChunk{
+			{Id: "1", Choices: []ChunkChoice{{Delta: ChunkDelta{Role: AssistantRoleType, Content: strPointer("")}}}},
+			{Id: "1", Choices: []ChunkChoice{{Delta: ChunkDelta{Content: strPointer("I'm Sorry Dave, ")}}}},
+			{Id: "1", Choices: []ChunkChoice{{Delta: ChunkDelta{Content: strPointer("I'm Afraid I Can't Do That")}, FinishReason: &stop}}},
+			{Id: "1", Choices: []ChunkChoice{}, Usage: &Usage{PromptTokens: 5, CompletionTokens: 10, TotalTokens: 15}},
+		}
+		for _, chunk := range chunks {
+			data, err := json.Marshal(chunk)
+			require.NoError(t, err)
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+			require.NoError(t, err)
+		}
+		_, err = fmt.Fprint(w, "data: [DONE]\n\n")
+		require.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	openAi, err := New(Config{
+		OpenAiKey: TEST_KEY,
+		GptModel:  TEST_MODEL,
+	}, WithRetryableHttpClient(testHttpClient()), WithUrl(svr.URL))
+	require.NoError(t, err)
+
+	deltas := ""
+	resp, err := openAi.ChatCompletionStream(ctx, []Message{{Role: UserRoleType, Content: strPointer("Open The pod bay doors, HAL.")}}, func(chunk ChatCompletionChunk) error {
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != nil {
+				deltas += *c.Delta.Content
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "I'm Sorry Dave, I'm Afraid I Can't Do That", *resp.Choices[0].Message.Content)
+	assert.Equal(t, deltas, *resp.Choices[0].Message.Content)
+	assert.True(t, resp.Choices[0].IsAssistantMessage())
+	assert.Equal(t, 15, resp.Usage.TotalTokens)
+}
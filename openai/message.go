@@ -14,7 +14,7 @@ type Message struct {
 	Role       RoleType   `json:"role"`
 	Name       *string    `json:"name,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallId string     `json:"tool_call_id"`
+	ToolCallId string     `json:"tool_call_id,omitempty"`
 }
 
 type ChatCompletionMessage struct {
@@ -0,0 +1,220 @@
+package ringchain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type ExecutionStatus string
+
+const (
+	ExecutionRunning   ExecutionStatus = "running"
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+)
+
+type NodeStatus string
+
+const (
+	// NodeQueued nodes are waiting for a worker or running
+	NodeQueued    NodeStatus = "queued"
+	NodeSucceeded NodeStatus = "succeeded"
+	NodeSkipped   NodeStatus = "skipped"
+)
+
+// Checkpoint is the state of an execution, it is saved every time a node completes.
+type Checkpoint struct {
+	ExecutionID string          `json:"execution_id"`
+	Status      ExecutionStatus `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	// Args are the args the execution was started with
+	Args map[string]any `json:"args"`
+	// Results are the results of the nodes that succeeded by hash, and by IterationHash for the nodes in loop bodies
+	Results map[string]map[string]any `json:"results"`
+	// Nodes is the status of every node the execution reached
+	Nodes map[string]NodeStatus `json:"nodes"`
+	// Inputs are the args of the queued nodes
+	Inputs    map[string]map[string]any `json:"inputs"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+func (c Checkpoint) clone() Checkpoint {
+	c.Args = maps.Clone(c.Args)
+	c.Results = maps.Clone(c.Results)
+	c.Nodes = maps.Clone(c.Nodes)
+	c.Inputs = maps.Clone(c.Inputs)
+	return c
+}
+
+// Checkpointer saves the checkpoints of executions so they can be resumed with Graph.Resume.
+// Implementations must be safe for concurrent use.
+type Checkpointer interface {
+	// Save should replace the checkpoint of the execution.
+	Save(ctx context.Context, checkpoint Checkpoint) error
+
+	// Load should return the last checkpoint of the execution, or ErrCheckpointNotFound.
+	Load(ctx context.Context, executionID string) (Checkpoint, error)
+
+	// Delete should delete the checkpoint of the execution. Deleting a checkpoint that doesn't exist is not an error.
+	Delete(ctx context.Context, executionID string) error
+}
+
+// WithCheckpointer saves a checkpoint of the execution every time a node completes.
+func WithCheckpointer(checkpointer Checkpointer) func(*GraphExecuteOptions) {
+	return func(opts *GraphExecuteOptions) {
+		opts.Checkpointer = checkpointer
+	}
+}
+
+// WithExecutionID sets the id of the execution, used by its checkpoints and events. A random one is generated by default.
+func WithExecutionID(executionID string) func(*GraphExecuteOptions) {
+	return func(opts *GraphExecuteOptions) {
+		opts.ExecutionID = executionID
+	}
+}
+
+func newExecutionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryCheckpointer keeps checkpoints in memory, they are lost when the process exits.
+type MemoryCheckpointer struct {
+	mu          sync.RWMutex
+	checkpoints map[string]Checkpoint
+}
+
+var _ Checkpointer = (*MemoryCheckpointer)(nil)
+
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{
+		checkpoints: make(map[string]Checkpoint),
+	}
+}
+
+func (c *MemoryCheckpointer) Save(ctx context.Context, checkpoint Checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkpoints[checkpoint.ExecutionID] = checkpoint.clone()
+	return nil
+}
+
+func (c *MemoryCheckpointer) Load(ctx context.Context, executionID string) (Checkpoint, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	checkpoint, ok := c.checkpoints[executionID]
+	if !ok {
+		return Checkpoint{}, ErrCheckpointNotFound
+	}
+	return checkpoint.clone(), nil
+}
+
+func (c *MemoryCheckpointer) Delete(ctx context.Context, executionID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checkpoints, executionID)
+	return nil
+}
+
+// FileCheckpointer keeps every checkpoint in a JSON file named after the execution id in a directory.
+// Files are written atomically so a crash never leaves a half written checkpoint. Node results must be
+// JSON serializable, and are loaded back as JSON values: numbers become float64 for instance.
+type FileCheckpointer struct {
+	dir string
+}
+
+var _ Checkpointer = (*FileCheckpointer)(nil)
+
+// NewFileCheckpointer creates a checkpointer in dir, the directory is created if it doesn't exist.
+func NewFileCheckpointer(dir string) (*FileCheckpointer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create checkpoint directory: %w", err)
+	}
+	return &FileCheckpointer{dir: dir}, nil
+}
+
+// validExecutionIDRe keeps execution ids from escaping the checkpoint directory.
+var validExecutionIDRe = regexp.MustCompile(`^[\w.-]+$`)
+
+func (c *FileCheckpointer) path(executionID string) (string, error) {
+	if !validExecutionIDRe.MatchString(executionID) || executionID == "." || executionID == ".." {
+		return "", fmt.Errorf("invalid execution id %q", executionID)
+	}
+	return filepath.Join(c.dir, executionID+".json"), nil
+}
+
+func (c *FileCheckpointer) Save(ctx context.Context, checkpoint Checkpoint) error {
+	path, err := c.path(checkpoint.ExecutionID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("could not marshal checkpoint %s: %w", checkpoint.ExecutionID, err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, checkpoint.ExecutionID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *FileCheckpointer) Load(ctx context.Context, executionID string) (Checkpoint, error) {
+	path, err := c.path(executionID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Checkpoint{}, ErrCheckpointNotFound
+	} else if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return Checkpoint{}, fmt.Errorf("could not unmarshal checkpoint %s: %w", executionID, err)
+	}
+	return checkpoint, nil
+}
+
+func (c *FileCheckpointer) Delete(ctx context.Context, executionID string) error {
+	path, err := c.path(executionID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package ringchain
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGraph_Resume(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	fileCheckpointer, err := NewFileCheckpointer(t.TempDir())
+	require.NoError(t, err)
+	checkpointers := map[string]Checkpointer{
+		"Memory": NewMemoryCheckpointer(),
+		"File":   fileCheckpointer,
+	}
+
+	for name, checkpointer := range checkpointers {
+		t.Run(name, func(t *testing.T) {
+			first, second, last := &atomic.Int32{}, &atomic.Int32{}, &atomic.Int32{}
+			g := NewGraph()
+			require.NoError(t, g.AddNode("1", FlakyNode{name: "1", attempts: first}))
+			require.NoError(t, g.AddNode("2", FlakyNode{name: "2", attempts: second}))
+			require.NoError(t, g.AddNode("3", FlakyNode{name: "3", failures: 1, attempts: last}))
+			require.NoError(t, g.AddEdge("1", "2"))
+			require.NoError(t, g.AddEdge("2", "3"))
+
+			opts := []func(*GraphExecuteOptions){WithCheckpointer(checkpointer), WithExecutionID("execution")}
+			_, err := g.Execute(ctx, logger, map[string]any{"input": "value"}, opts...)
+			require.ErrorIs(t, err, errTransient)
+
+			checkpoint, err := checkpointer.Load(ctx, "execution")
+			require.NoError(t, err)
+			assert.Equal(t, ExecutionFailed, checkpoint.Status)
+			assert.Equal(t, errTransient.Error(), checkpoint.Error)
+			assert.Equal(t, map[string]any{"input": "value"}, checkpoint.Args)
+			assert.Equal(t, map[string]NodeStatus{"1": NodeSucceeded, "2": NodeSucceeded, "3": NodeQueued}, checkpoint.Nodes)
+			assert.Equal(t, map[string]map[string]any{"3": {"n_2": true}}, checkpoint.Inputs)
+			assert.Contains(t, checkpoint.Results, "2")
+
+			res, err := g.Resume(ctx, logger, "execution", opts...)
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), first.Load())
+			assert.Equal(t, int32(1), second.Load())
+			assert.Equal(t, int32(2), last.Load())
+			assert.Equal(t, map[string]any{"n_3": true}, res["3"])
+			assert.Equal(t, map[string]any{"n_1": true}, res["1"])
+
+			checkpoint, err = checkpointer.Load(ctx, "execution")
+			require.NoError(t, err)
+			assert.Equal(t, ExecutionSucceeded, checkpoint.Status)
+			assert.Empty(t, checkpoint.Inputs)
+
+			require.NoError(t, checkpointer.Delete(ctx, "execution"))
+			_, err = checkpointer.Load(ctx, "execution")
+			assert.ErrorIs(t, err, ErrCheckpointNotFound)
+		})
+	}
+
+	t.Run("Loop", func(t *testing.T) {
+		draft, critique, publish := &atomic.Int32{}, &atomic.Int32{}, &atomic.Int32{}
+		g := NewGraph()
+		require.NoError(t, g.AddNode("draft", FlakyNode{name: "draft", attempts: draft}))
+		require.NoError(t, g.AddNode("critique", FlakyNode{name: "critique", attempts: critique}))
+		require.NoError(t, g.AddNode("publish", FlakyNode{name: "publish", failures: 1, attempts: publish}))
+		require.NoError(t, g.AddEdge("draft", "critique"))
+		require.NoError(t, g.AddEdge("critique", "publish"))
+		require.NoError(t, g.AddEdge("critique", "draft", WithLoop(3, nil)))
+
+		opts := []func(*GraphExecuteOptions){WithCheckpointer(NewMemoryCheckpointer()), WithExecutionID("loop")}
+		_, err := g.Execute(ctx, logger, map[string]any{}, opts...)
+		require.ErrorIs(t, err, errTransient)
+
+		res, err := g.Resume(ctx, logger, "loop", opts...)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), draft.Load())
+		assert.Equal(t, int32(3), critique.Load())
+		assert.Equal(t, int32(2), publish.Load())
+		assert.Contains(t, res, IterationHash("draft", 2))
+	})
+
+	t.Run("NoCheckpointer", func(t *testing.T) {
+		_, err := NewGraph().Resume(ctx, logger, "execution")
+		assert.ErrorIs(t, err, ErrNoCheckpointer)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := NewGraph().Resume(ctx, logger, "missing", WithCheckpointer(NewMemoryCheckpointer()))
+		assert.ErrorIs(t, err, ErrCheckpointNotFound)
+	})
+}
+
+func TestFileCheckpointer_InvalidID(t *testing.T) {
+	checkpointer, err := NewFileCheckpointer(t.TempDir())
+	require.NoError(t, err)
+
+	err = checkpointer.Save(context.Background(), Checkpoint{ExecutionID: "../escape"})
+	assert.Error(t, err)
+}
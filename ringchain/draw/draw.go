@@ -3,6 +3,8 @@ package draw
 import (
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"text/template"
 
 	"github.com/dskart/gollum/ringchain"
@@ -12,11 +14,16 @@ const dotTemplate = `strict {{.GraphType}} {
 {{range $k, $v := .Attributes}}
 	{{$k}}="{{$v}}";
 {{end}}
-{{range $s := .Statements}}
-	"{{.Source}}" {{if .Target}}{{$.EdgeOperator}} "{{.Target}}" [ {{range $k, $v := .EdgeAttributes}}{{$k}}="{{$v}}", {{end}} weight={{.EdgeWeight}} ]{{else}}[ {{range $k, $v := .SourceAttributes}}{{$k}}="{{$v}}", {{end}} weight={{.SourceWeight}} ]{{end}};
-{{end}}
+{{template "statements" .}}
 }
-`
+{{define "statements"}}{{range $s := .Statements}}{{if .Cluster}}
+	subgraph "cluster_{{.Cluster.Name}}" {
+		label="{{.Cluster.Label}}";
+{{template "statements" .Cluster}}
+	};
+{{else}}
+	"{{.Source}}" {{if .Target}}{{$.EdgeOperator}} "{{.Target}}" [ {{range $k, $v := .EdgeAttributes}}{{$k}}="{{$v}}", {{end}} weight={{.EdgeWeight}} ]{{else}}[ {{range $k, $v := .SourceAttributes}}{{$k}}="{{$v}}", {{end}} weight={{.SourceWeight}} ]{{end}};
+{{end}}{{end}}{{end}}`
 
 type description struct {
 	GraphType    string
@@ -32,6 +39,15 @@ type statement struct {
 	SourceAttributes map[string]string
 	EdgeWeight       int
 	EdgeAttributes   map[string]string
+	// Cluster is set for the statements of subgraphs
+	Cluster *cluster
+}
+
+type cluster struct {
+	Name         string
+	Label        string
+	EdgeOperator string
+	Statements   []statement
 }
 
 func DOT(g *ringchain.Graph, w io.Writer, options ...func(*description)) error {
@@ -64,32 +80,110 @@ func generateDOT(g *ringchain.Graph, options ...func(*description)) (description
 	desc.GraphType = "digraph"
 	desc.EdgeOperator = "->"
 
-	adjacencyMap, err := g.SuccessorMap()
+	statements, _, err := graphStatements(g, "")
 	if err != nil {
 		return desc, err
 	}
+	desc.Statements = statements
+
+	return desc, nil
+}
+
+// ends are the nodes edges to and from a node are drawn to, which are the entry and exit nodes of subgraphs.
+type ends struct {
+	entries []string
+	exits   []string
+}
+
+// graphStatements returns the statements of the graph with node names prefixed by prefix, subgraphs are drawn as
+// clusters of their nodes named by their path. Nodes and edges are sorted so the output is deterministic.
+func graphStatements(g *ringchain.Graph, prefix string) ([]statement, map[string]ends, error) {
+	statements := make([]statement, 0)
+
+	adjacencyMap, err := g.SuccessorMap()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for node, adjacencies := range adjacencyMap {
-		_, err := g.Node(node)
+	nodes := slices.Sorted(maps.Keys(adjacencyMap))
+	nodeEnds := make(map[string]ends, len(adjacencyMap))
+	for _, node := range nodes {
+		n, err := g.Node(node)
 		if err != nil {
-			return desc, err
+			return nil, nil, err
 		}
+		name := prefix + node
 
-		stmt := statement{
-			Source: node,
+		subgraph, ok := n.(*ringchain.Subgraph)
+		if !ok {
+			stmt := statement{
+				Source:           name,
+				SourceAttributes: make(map[string]string),
+			}
+			// routers decide at runtime which successors run
+			if _, ok := n.(ringchain.Router); ok {
+				stmt.SourceAttributes["shape"] = "diamond"
+			}
+			statements = append(statements, stmt)
+			nodeEnds[node] = ends{entries: []string{name}, exits: []string{name}}
+			continue
 		}
-		desc.Statements = append(desc.Statements, stmt)
 
-		for adjacency := range adjacencies {
-			stmt := statement{
-				Source: node,
-				Target: adjacency,
+		inner, innerEnds, err := graphStatements(subgraph.Graph(), name+ringchain.PathSeparator)
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, statement{Cluster: &cluster{
+			Name:         name,
+			Label:        node,
+			EdgeOperator: "->",
+			Statements:   inner,
+		}})
+
+		entries, err := subgraph.EntryNodes()
+		if err != nil {
+			return nil, nil, err
+		}
+		exits, err := subgraph.ExitNodes()
+		if err != nil {
+			return nil, nil, err
+		}
+		e := ends{}
+		for _, entry := range entries {
+			e.entries = append(e.entries, innerEnds[entry].entries...)
+		}
+		for _, exit := range exits {
+			e.exits = append(e.exits, innerEnds[exit].exits...)
+		}
+		nodeEnds[node] = e
+	}
+
+	for _, node := range nodes {
+		adjacencies := adjacencyMap[node]
+		for _, adjacency := range slices.Sorted(maps.Keys(adjacencies)) {
+			edge := adjacencies[adjacency]
+			attributes := make(map[string]string)
+			if edge.Condition != nil {
+				attributes["style"] = "dashed"
+			}
+			if edge.Loop != nil {
+				attributes["style"] = "dotted"
+				attributes["label"] = fmt.Sprintf("loop x%d", edge.Loop.MaxIterations)
+			}
+
+			for _, source := range nodeEnds[node].exits {
+				for _, target := range nodeEnds[adjacency].entries {
+					statements = append(statements, statement{
+						Source:         source,
+						Target:         target,
+						EdgeAttributes: maps.Clone(attributes),
+					})
+				}
 			}
-			desc.Statements = append(desc.Statements, stmt)
 		}
 	}
 
-	return desc, nil
+	return statements, nodeEnds, nil
 }
 
 func renderDOT(w io.Writer, d description) error {
@@ -0,0 +1,63 @@
+package draw
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"os"
+	"testing"
+
+	"github.com/dskart/gollum/ringchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testNode struct {
+	name string
+}
+
+func (n testNode) Name() string {
+	return n.name
+}
+
+func (n testNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	return maps.Clone(args), nil
+}
+
+type testRouter struct {
+	testNode
+}
+
+func (n testRouter) Route(result map[string]any) ([]string, error) {
+	return nil, nil
+}
+
+func TestDOT(t *testing.T) {
+	inner := ringchain.NewGraph()
+	require.NoError(t, inner.AddNode("search", testNode{name: "search"}))
+	require.NoError(t, inner.AddNode("summarize", testNode{name: "summarize"}))
+	require.NoError(t, inner.AddEdge("search", "summarize"))
+	research, err := ringchain.NewSubgraph("research", inner)
+	require.NoError(t, err)
+
+	g := ringchain.NewGraph()
+	require.NoError(t, g.AddNode("route", testRouter{testNode{name: "route"}}))
+	require.NoError(t, g.AddNode("research", research))
+	require.NoError(t, g.AddNode("answer", testNode{name: "answer"}))
+	require.NoError(t, g.AddEdge("route", "research", ringchain.WithWhen("kind", "research")))
+	require.NoError(t, g.AddEdge("route", "answer"))
+	require.NoError(t, g.AddEdge("research", "answer"))
+	require.NoError(t, g.AddEdge("answer", "route", ringchain.WithLoop(3, nil)))
+
+	// the router is a diamond, the subgraph a cluster its edges go in and out of through its entry and exit nodes
+	expected, err := os.ReadFile("testdata/agent.dot")
+	require.NoError(t, err)
+
+	// the output is compared a few times as maps would make it differ from one call to the other
+	for range 5 {
+		var buf bytes.Buffer
+		require.NoError(t, DOT(g, &buf, GraphAttribute("label", "agent")))
+		assert.Equal(t, string(expected), buf.String())
+	}
+}
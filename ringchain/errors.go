@@ -3,11 +3,23 @@ package ringchain
 import "errors"
 
 var (
-	ErrNodeNotFound      = errors.New("vertex not found")
-	ErrNodeAlreadyExists = errors.New("vertex already exists")
-	ErrEdgeNotFound      = errors.New("edge not found")
-	ErrEdgeAlreadyExists = errors.New("edge already exists")
-	ErrEdgeCreatesCycle  = errors.New("edge would create a cycle")
-	ErrNodeHasEdges      = errors.New("vertex has edges")
-	ErrGraphNotInit      = errors.New("graph not Init()")
+	ErrNodeNotFound       = errors.New("vertex not found")
+	ErrNodeAlreadyExists  = errors.New("vertex already exists")
+	ErrEdgeNotFound       = errors.New("edge not found")
+	ErrEdgeAlreadyExists  = errors.New("edge already exists")
+	ErrEdgeCreatesCycle   = errors.New("edge would create a cycle")
+	ErrNodeHasEdges       = errors.New("vertex has edges")
+	ErrGraphNotInit       = errors.New("graph not Init()")
+	ErrUnknownJoin        = errors.New("unknown join type")
+	ErrInvalidRoute       = errors.New("route is not a successor")
+	ErrInvalidLoop        = errors.New("invalid loop")
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	ErrNoCheckpointer     = errors.New("no checkpointer")
+	ErrUnknownInputMode   = errors.New("unknown input mode")
+	ErrUnknownOutput      = errors.New("unknown output")
+	ErrInputCollision     = errors.New("input collision")
+	ErrUnknownNodeType    = errors.New("unknown node type")
+	ErrNodeTypeExists     = errors.New("node type already registered")
+	ErrNotExportable      = errors.New("graph is not exportable")
+	ErrInvalidSubgraph    = errors.New("invalid subgraph")
 )
@@ -0,0 +1,93 @@
+package ringchain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type EventType string
+
+const (
+	ExecutionStartedEventType  EventType = "execution_started"
+	ExecutionFinishedEventType EventType = "execution_finished"
+	NodeQueuedEventType        EventType = "node_queued"
+	NodeStartedEventType       EventType = "node_started"
+	NodeRetryingEventType      EventType = "node_retrying"
+	NodeSucceededEventType     EventType = "node_succeeded"
+	NodeFailedEventType        EventType = "node_failed"
+	NodeSkippedEventType       EventType = "node_skipped"
+)
+
+// Event is emitted while a graph executes. Which fields are set depends on the event type.
+type Event struct {
+	Type        EventType `json:"type"`
+	ExecutionID string    `json:"execution_id,omitempty"`
+	NodeHash    string    `json:"node_hash,omitempty"`
+	Time        time.Time `json:"time"`
+	// Input is set on node queued and started events
+	Input map[string]any `json:"input,omitempty"`
+	// Output is set on node succeeded events
+	Output map[string]any `json:"output,omitempty"`
+	// Attempt is the 1-based attempt of node started, retrying, succeeded and failed events
+	Attempt int `json:"attempt,omitempty"`
+	// Latency is the time a node or the execution took, it is set on node succeeded and failed events and execution finished events
+	Latency time.Duration `json:"latency,omitempty"`
+	// Replayed is set on node succeeded events of nodes whose checkpointed result was reused by Resume
+	Replayed bool   `json:"replayed,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Results is set on execution finished events of executions that succeeded
+	Results map[string]map[string]any `json:"results,omitempty"`
+}
+
+// WithEventHandler calls handler for every event emitted during the execution.
+// The handler is never called concurrently but it blocks the execution, so it should return quickly.
+func WithEventHandler(handler func(Event)) func(*GraphExecuteOptions) {
+	return func(opts *GraphExecuteOptions) {
+		opts.EventHandler = handler
+	}
+}
+
+type emitter struct {
+	mu          sync.Mutex
+	handler     func(Event)
+	executionID string
+}
+
+func (e *emitter) emit(event Event) {
+	if e == nil || e.handler == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event.ExecutionID = e.executionID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	e.handler(event)
+}
+
+// ExecuteEvents executes the graph in the background and returns its events over a channel.
+// The last event is the execution finished event, holding either the results or the error, then the channel is closed.
+// The channel must be drained or ctx canceled for the execution to finish.
+func (g *Graph) ExecuteEvents(ctx context.Context, logger *zap.Logger, args map[string]any, opts ...func(*GraphExecuteOptions)) <-chan Event {
+	events := make(chan Event)
+	send := func(event Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		opts = append(opts, WithEventHandler(send))
+		// the error is part of the execution finished event
+		_, _ = g.Execute(ctx, logger, args, opts...)
+	}()
+
+	return events
+}
@@ -0,0 +1,140 @@
+package ringchain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGraph_ExecuteEventHandler(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	// 1 -> 2 and 1 -> 3, 3 is skipped
+	g := NewGraph()
+	require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+	require.NoError(t, g.AddNode("2", FlakyNode{name: "2", failures: 1, attempts: &atomic.Int32{}}, WithRetry(2, nil)))
+	require.NoError(t, g.AddNode("3", TestNode{name: "3"}))
+	require.NoError(t, g.AddEdge("1", "2"))
+	require.NoError(t, g.AddEdge("1", "3", WithCondition(WhenEquals("missing", true))))
+
+	events := make([]Event, 0)
+	res, err := g.Execute(ctx, logger, map[string]any{"input": 1}, WithExecutionID("execution"), WithEventHandler(func(event Event) {
+		events = append(events, event)
+	}))
+	require.NoError(t, err)
+
+	types := make(map[string][]EventType)
+	for _, event := range events {
+		assert.Equal(t, "execution", event.ExecutionID)
+		assert.False(t, event.Time.IsZero())
+		types[event.NodeHash] = append(types[event.NodeHash], event.Type)
+	}
+
+	assert.Equal(t, []EventType{ExecutionStartedEventType, ExecutionFinishedEventType}, types[""])
+	assert.Equal(t, []EventType{NodeQueuedEventType, NodeStartedEventType, NodeSucceededEventType}, types["1"])
+	assert.Equal(t, []EventType{NodeQueuedEventType, NodeStartedEventType, NodeRetryingEventType, NodeStartedEventType, NodeSucceededEventType}, types["2"])
+	assert.Equal(t, []EventType{NodeSkippedEventType}, types["3"])
+
+	assert.Equal(t, ExecutionStartedEventType, events[0].Type)
+	assert.Equal(t, map[string]any{"input": 1}, events[0].Input)
+	last := events[len(events)-1]
+	assert.Equal(t, ExecutionFinishedEventType, last.Type)
+	assert.Equal(t, res, last.Results)
+	assert.Positive(t, last.Latency)
+
+	for _, event := range events {
+		if event.NodeHash == "2" && event.Type == NodeSucceededEventType {
+			assert.Equal(t, 2, event.Attempt)
+			assert.Equal(t, map[string]any{"n_2": true}, event.Output)
+			assert.Positive(t, event.Latency)
+		}
+	}
+}
+
+func TestGraph_ExecuteEvents(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	g := NewGraph()
+	require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+	require.NoError(t, g.AddNode("2", ErrorNode{name: "2"}))
+	require.NoError(t, g.AddEdge("1", "2"))
+
+	var last Event
+	failed := 0
+	for event := range g.ExecuteEvents(ctx, logger, map[string]any{}) {
+		if event.Type == NodeFailedEventType {
+			assert.Equal(t, "2", event.NodeHash)
+			assert.Equal(t, "node is broken.", event.Error)
+			failed++
+		}
+		last = event
+	}
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, ExecutionFinishedEventType, last.Type)
+	assert.Equal(t, "node is broken.", last.Error)
+	assert.NotEmpty(t, last.ExecutionID)
+}
+
+func TestGraph_ExecuteEventsQueuedFailure(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	runs := &atomic.Int64{}
+	g := NewGraph()
+	require.NoError(t, g.AddNode("broken", ErrorNode{name: "broken"}))
+	for i := range 50 {
+		hash := fmt.Sprintf("root_%d", i)
+		require.NoError(t, g.AddNode(hash, CountingNode{name: hash, runs: runs}))
+	}
+
+	// the execution fails while most roots are still queued, their workers must not emit after it finished
+	events := make([]Event, 0)
+	for event := range g.ExecuteEvents(ctx, logger, map[string]any{}, WithNumWorkers(4)) {
+		events = append(events, event)
+	}
+	require.NotEmpty(t, events)
+	finished := slices.IndexFunc(events, func(event Event) bool {
+		return event.Type == ExecutionFinishedEventType
+	})
+	assert.Equal(t, len(events)-1, finished)
+	assert.Equal(t, "node is broken.", events[finished].Error)
+}
+
+// unlistableStore is a store whose edges can't be listed, so the execution fails before any node runs.
+type unlistableStore struct {
+	Store
+}
+
+func (unlistableStore) ListEdges() ([]Edge, error) {
+	return nil, fmt.Errorf("store is unlistable")
+}
+
+func TestGraph_ExecuteEventsEarlyFailure(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	g := NewGraph()
+	require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+	g.store = unlistableStore{g.store}
+
+	events := make([]Event, 0)
+	_, err := g.Execute(ctx, logger, map[string]any{"input": 1}, WithEventHandler(func(event Event) {
+		events = append(events, event)
+	}))
+	require.Error(t, err)
+
+	types := make([]EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []EventType{ExecutionStartedEventType, ExecutionFinishedEventType}, types)
+	assert.Equal(t, err.Error(), events[1].Error)
+}
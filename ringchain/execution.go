@@ -0,0 +1,364 @@
+package ringchain
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// joinState tracks the incoming edges of a node during an execution.
+type joinState struct {
+	// remaining is the number of predecessors that are not done or skipped yet
+	remaining int
+	// active is the number of incoming edges that were activated
+	active  int
+	started bool
+	done    bool
+	input   map[string]any
+	// sources are the predecessors that added each key of a merged input
+	sources map[string]string
+}
+
+// execution holds the scheduling state of a single Graph.Execute call. It is only used by the scheduling goroutine.
+type execution struct {
+	graph          *Graph
+	logger         *zap.Logger
+	workerPool     *workerPool
+	successorMap   map[string]map[string]Edge
+	predecessorMap map[string]map[string]Edge
+
+	joins map[string]*joinState
+	loops []*loopState
+	// loopTails are the loops by the hash of their tail
+	loopTails map[string]*loopState
+	// inLoop are the nodes that are part of a loop body, their result is also recorded for every iteration
+	inLoop map[string]bool
+	runs   map[string]int
+
+	args map[string]any
+	// rootArgs replace args for the root nodes if set
+	rootArgs map[string]map[string]any
+	// pending counts the nodes queued or running
+	pending int
+	results map[string]map[string]any
+
+	checkpointer Checkpointer
+	checkpoint   Checkpoint
+	// previous are the results of the resumed execution, nodes with a previous result are not run again
+	previous map[string]map[string]any
+	// replayed are the completions of the nodes reusing a previous result
+	replayed []nodeCompletion
+
+	emitter *emitter
+}
+
+func newExecution(g *Graph, logger *zap.Logger, successorMap, predecessorMap map[string]map[string]Edge, options GraphExecuteOptions) (*execution, error) {
+	loops, err := newLoopStates(successorMap, predecessorMap)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &execution{
+		graph:          g,
+		logger:         logger,
+		successorMap:   successorMap,
+		predecessorMap: predecessorMap,
+		joins:          make(map[string]*joinState, len(predecessorMap)),
+		loops:          loops,
+		loopTails:      make(map[string]*loopState, len(loops)),
+		inLoop:         make(map[string]bool),
+		runs:           make(map[string]int),
+		results:        make(map[string]map[string]any, len(predecessorMap)),
+		checkpointer:   options.Checkpointer,
+		rootArgs:       options.rootArgs,
+		checkpoint: Checkpoint{
+			ExecutionID: options.ExecutionID,
+			Status:      ExecutionRunning,
+			Nodes:       make(map[string]NodeStatus, len(predecessorMap)),
+			Inputs:      make(map[string]map[string]any),
+		},
+	}
+	for nodeHash := range predecessorMap {
+		e.joins[nodeHash] = &joinState{remaining: e.inDegree(nodeHash)}
+	}
+	for _, loop := range loops {
+		e.loopTails[loop.tail] = loop
+		maps.Copy(e.inLoop, loop.body)
+	}
+	return e, nil
+}
+
+// inDegree returns the number of incoming edges of a node, loop edges excluded.
+func (e *execution) inDegree(nodeHash string) int {
+	n := 0
+	for _, edge := range e.predecessorMap[nodeHash] {
+		if edge.Loop == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// start queues the nodes without predecessors with the args of the execution.
+func (e *execution) start(ctx context.Context, args map[string]any) error {
+	e.args = args
+	e.checkpoint.Args = args
+	e.checkpoint.Results = e.results
+	if err := e.save(ctx); err != nil {
+		return err
+	}
+
+	for nodeHash, join := range e.joins {
+		if join.remaining == 0 {
+			input := args
+			if e.rootArgs != nil {
+				input = e.rootArgs[nodeHash]
+				if input == nil {
+					input = make(map[string]any)
+				}
+			}
+			if err := e.enqueue(nodeHash, input); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *execution) enqueue(nodeHash string, input map[string]any) error {
+	node, err := e.graph.Node(nodeHash)
+	if err != nil {
+		return err
+	}
+	e.joins[nodeHash].started = true
+	e.checkpoint.Nodes[nodeHash] = NodeQueued
+	e.checkpoint.Inputs[nodeHash] = input
+	e.pending++
+	e.emitter.emit(Event{Type: NodeQueuedEventType, NodeHash: nodeHash, Input: input})
+
+	if result, ok := e.previous[e.resultHash(nodeHash)]; ok {
+		completion := nodeCompletion{NodeHash: nodeHash, Result: result, replayed: true}
+		if router, ok := node.(Router); ok {
+			completion.Routes, completion.Err = router.Route(result)
+			if completion.Err == nil && completion.Routes == nil {
+				completion.Routes = []string{}
+			}
+		}
+		e.replayed = append(e.replayed, completion)
+		return nil
+	}
+
+	e.workerPool.AddNodeJob(e.logger, nodeHash, node, e.graph.nodeOptions[nodeHash], input)
+	return nil
+}
+
+// resultHash returns the hash the result of the next run of a node is recorded with in a checkpoint.
+func (e *execution) resultHash(nodeHash string) string {
+	if e.inLoop[nodeHash] {
+		return IterationHash(nodeHash, e.runs[nodeHash])
+	}
+	return nodeHash
+}
+
+// complete records the result of a node, schedules what comes next and saves a checkpoint.
+func (e *execution) complete(ctx context.Context, completion nodeCompletion) error {
+	e.pending--
+	if completion.Err != nil {
+		e.emitter.emit(Event{Type: NodeFailedEventType, NodeHash: completion.NodeHash, Attempt: completion.Attempts, Latency: completion.Latency, Error: completion.Err.Error()})
+		return &nodeError{nodeHash: completion.NodeHash, err: completion.Err}
+	}
+	e.emitter.emit(Event{
+		Type:     NodeSucceededEventType,
+		NodeHash: completion.NodeHash,
+		Output:   completion.Result,
+		Attempt:  completion.Attempts,
+		Latency:  completion.Latency,
+		Replayed: completion.replayed,
+	})
+
+	e.joins[completion.NodeHash].done = true
+	e.results[completion.NodeHash] = completion.Result
+	if e.inLoop[completion.NodeHash] {
+		e.results[IterationHash(completion.NodeHash, e.runs[completion.NodeHash])] = completion.Result
+	}
+	e.runs[completion.NodeHash]++
+	e.checkpoint.Nodes[completion.NodeHash] = NodeSucceeded
+	delete(e.checkpoint.Inputs, completion.NodeHash)
+
+	result := completion.Result
+	if result == nil {
+		// an empty result still activates the outgoing edges
+		result = map[string]any{}
+	}
+	if err := e.resolve(completion.NodeHash, result, completion.Routes); err != nil {
+		return err
+	}
+
+	for _, loop := range e.loops {
+		if loop.restart != nil {
+			if err := e.restart(loop); err != nil {
+				return err
+			}
+		}
+	}
+	return e.save(ctx)
+}
+
+// resolve marks every outgoing edge of a done or skipped node as active or not, queues the successors
+// that are ready and propagates the skip to the successors left without any active edge.
+// A nil result means the node was skipped, nil routes mean all successors are routed to.
+func (e *execution) resolve(nodeHash string, result map[string]any, routes []string) error {
+	type resolved struct {
+		nodeHash string
+		result   map[string]any
+		routes   []string
+	}
+	queue := []resolved{{nodeHash, result, routes}}
+	for len(queue) > 0 {
+		done := queue[0]
+		queue = queue[1:]
+
+		var routed map[string]bool
+		if done.routes != nil {
+			routed = make(map[string]bool, len(done.routes))
+			for _, route := range done.routes {
+				if _, ok := e.successorMap[done.nodeHash][route]; !ok {
+					return fmt.Errorf("router %v routed to %v: %w", done.nodeHash, route, ErrInvalidRoute)
+				}
+				routed[route] = true
+			}
+		}
+
+		// the successors of the tail of a loop wait for the loop to exit
+		if loop, ok := e.loopTails[done.nodeHash]; ok && loop.continues(done.result, routed) {
+			loop.restart = done.result
+			continue
+		}
+
+		for successor, edge := range e.successorMap[done.nodeHash] {
+			if edge.Loop != nil {
+				continue
+			}
+
+			join := e.joins[successor]
+			join.remaining--
+
+			active := done.result != nil && (routed == nil || routed[successor]) && (edge.Condition == nil || edge.Condition(done.result))
+			if active && !join.started {
+				join.active++
+				options := e.graph.nodeOptions[successor]
+				if join.input == nil {
+					join.input = newInput(options, e.args)
+					join.sources = make(map[string]string)
+				}
+				// Save the finished node result in the next node input map
+				if err := addInput(options, join.input, join.sources, edge, done.result); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case join.started:
+			case active && e.graph.nodeOptions[successor].join == JoinAny, join.remaining == 0 && join.active > 0:
+				if err := e.enqueue(successor, join.input); err != nil {
+					return err
+				}
+				join.input, join.sources = nil, nil
+			case join.remaining == 0:
+				e.checkpoint.Nodes[successor] = NodeSkipped
+				e.emitter.emit(Event{Type: NodeSkippedEventType, NodeHash: successor})
+				queue = append(queue, resolved{nodeHash: successor})
+			}
+		}
+	}
+	return nil
+}
+
+// restart runs the head of the loop again once no node of its body is running anymore.
+func (e *execution) restart(loop *loopState) error {
+	for nodeHash := range loop.body {
+		if join := e.joins[nodeHash]; join.started && !join.done {
+			return nil
+		}
+	}
+
+	for nodeHash := range loop.body {
+		*e.joins[nodeHash] = joinState{remaining: e.inDegree(nodeHash)}
+	}
+	// the iterations of the loops nested in the body start over
+	for _, nested := range e.loops {
+		if nested != loop && loop.body[nested.tail] {
+			nested.iterations = 0
+			nested.restart = nil
+		}
+	}
+
+	options := e.graph.nodeOptions[loop.head]
+	input := newInput(options, e.args)
+	if err := addInput(options, input, make(map[string]string), loop.edge, loop.restart); err != nil {
+		return err
+	}
+	loop.restart = nil
+	return e.enqueue(loop.head, input)
+}
+
+// run starts the execution and waits for the nodes to complete until there is nothing left to run.
+// The execution finished event is left to the caller, once the workers are done.
+func (e *execution) run(ctx context.Context, args map[string]any) (map[string]map[string]any, error) {
+	if err := e.start(ctx, args); err != nil {
+		return nil, err
+	}
+
+	if err := e.wait(ctx); err != nil {
+		e.checkpoint.Status = ExecutionFailed
+		e.checkpoint.Error = err.Error()
+		// the context might be the reason of the failure
+		if saveErr := e.save(context.WithoutCancel(ctx)); saveErr != nil {
+			e.logger.Warn("could not save checkpoint", zap.Error(saveErr))
+		}
+		return nil, err
+	}
+
+	e.checkpoint.Status = ExecutionSucceeded
+	if err := e.save(ctx); err != nil {
+		return nil, err
+	}
+	return e.results, nil
+}
+
+func (e *execution) wait(ctx context.Context) error {
+	for e.pending > 0 {
+		if len(e.replayed) > 0 {
+			completion := e.replayed[0]
+			e.replayed = e.replayed[1:]
+			if err := e.complete(ctx, completion); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case completion := <-e.workerPool.Completions():
+			if err := e.complete(ctx, completion); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *execution) save(ctx context.Context) error {
+	if e.checkpointer == nil {
+		return nil
+	}
+	e.checkpoint.UpdatedAt = time.Now()
+	if err := e.checkpointer.Save(ctx, e.checkpoint); err != nil {
+		return fmt.Errorf("could not save checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package ringchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+type fileStoreOp string
+
+const (
+	addNodeOp    fileStoreOp = "add_node"
+	removeNodeOp fileStoreOp = "remove_node"
+	addEdgeOp    fileStoreOp = "add_edge"
+	updateEdgeOp fileStoreOp = "update_edge"
+	removeEdgeOp fileStoreOp = "remove_edge"
+)
+
+// fileStoreRecord is a line of the log of a FileStore.
+type fileStoreRecord struct {
+	Op   fileStoreOp `json:"op"`
+	Node *NodeSpec   `json:"node,omitempty"`
+	Edge *EdgeSpec   `json:"edge,omitempty"`
+	// Hash is the removed node, Source and Target the removed edge
+	Hash   string `json:"hash,omitempty"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// FileStore is a durable SpecStore that appends every change of the graph to a JSON lines file, and replays it
+// when the file is opened again. Nodes are persisted as their NodeSpec and rebuilt with a Registry, edges must be
+// exportable to an EdgeSpec. The graph is also kept in memory so reads never touch the file. Configs and
+// conditions are loaded back as JSON values: numbers become float64 for instance.
+//
+// Every change is synced to disk before it is applied. A change that was being written when the process crashed
+// is dropped when the file is opened again.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	memory *MemoryStore
+	specs  map[string]NodeSpec
+}
+
+var _ SpecStore = (*FileStore)(nil)
+
+// OpenFileStore opens the store at path, building its nodes with the node types of the registry.
+// The file is created if it doesn't exist.
+func OpenFileStore(path string, registry *Registry) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open store: %w", err)
+	}
+
+	s := &FileStore{
+		path:   path,
+		file:   file,
+		memory: NewMemoryStore(),
+		specs:  make(map[string]NodeSpec),
+	}
+	if err := s.replay(registry); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("could not replay store %v: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) replay(registry *Registry) error {
+	reader := bufio.NewReader(s.file)
+	offset := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a partial last line is a change that was never acknowledged
+			if len(bytes.TrimSpace(line)) > 0 {
+				if err := s.file.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		} else if err != nil {
+			return err
+		}
+
+		var record fileStoreRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("invalid record at offset %d: %w", offset, err)
+		}
+		if err := s.apply(record, registry); err != nil {
+			return fmt.Errorf("could not apply record at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+	}
+
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply applies a replayed record to the in-memory graph.
+func (s *FileStore) apply(record fileStoreRecord, registry *Registry) error {
+	switch record.Op {
+	case addNodeOp:
+		if record.Node == nil {
+			return fmt.Errorf("missing node")
+		}
+		node, err := registry.New(record.Node.Type, record.Node.Config)
+		if err != nil {
+			return fmt.Errorf("node %v: %w", record.Node.Name, err)
+		}
+		if err := s.memory.AddNode(record.Node.Name, node); err != nil {
+			return err
+		}
+		s.specs[record.Node.Name] = *record.Node
+	case removeNodeOp:
+		if err := s.memory.RemoveNode(record.Hash); err != nil {
+			return err
+		}
+		delete(s.specs, record.Hash)
+	case addEdgeOp:
+		if record.Edge == nil {
+			return fmt.Errorf("missing edge")
+		}
+		return s.memory.AddEdge(record.Edge.Source, record.Edge.Target, record.Edge.edge())
+	case updateEdgeOp:
+		if record.Edge == nil {
+			return fmt.Errorf("missing edge")
+		}
+		return s.memory.UpdateEdge(record.Edge.Source, record.Edge.Target, record.Edge.edge())
+	case removeEdgeOp:
+		return s.memory.RemoveEdge(record.Source, record.Target)
+	default:
+		return fmt.Errorf("unknown op %q", record.Op)
+	}
+	return nil
+}
+
+// append syncs the record to the end of the file.
+func (s *FileStore) append(record fileStoreRecord) error {
+	if s.file == nil {
+		return fmt.Errorf("store %v is closed", s.path)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not marshal %v record: %w", record.Op, err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// AddNode always fails with ErrNotExportable: a node without a type couldn't be rebuilt when the store is opened
+// again. Use AddNodeSpec, as Graph does.
+func (s *FileStore) AddNode(hash string, value Node) error {
+	return s.AddNodeSpec(NodeSpec{Name: hash}, value)
+}
+
+// AddNodeSpec adds a node along with the spec it is rebuilt from when the store is opened again.
+// The spec must have a type, or ErrNotExportable is returned.
+func (s *FileStore) AddNodeSpec(spec NodeSpec, value Node) error {
+	if spec.Type == "" {
+		return fmt.Errorf("%w: node %v was added without a type", ErrNotExportable, spec.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memory.Node(spec.Name); err == nil {
+		return ErrNodeAlreadyExists
+	}
+	if err := s.append(fileStoreRecord{Op: addNodeOp, Node: &spec}); err != nil {
+		return err
+	}
+	if err := s.memory.AddNode(spec.Name, value); err != nil {
+		return err
+	}
+	s.specs[spec.Name] = spec
+	return nil
+}
+
+func (s *FileStore) NodeSpec(hash string) (NodeSpec, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spec, ok := s.specs[hash]
+	if !ok {
+		return NodeSpec{}, ErrNodeNotFound
+	}
+	return spec, nil
+}
+
+func (s *FileStore) Node(hash string) (Node, error) {
+	return s.memory.Node(hash)
+}
+
+func (s *FileStore) RemoveNode(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memory.Node(hash); err != nil {
+		return err
+	}
+	if !s.isolated(hash) {
+		return ErrNodeHasEdges
+	}
+	if err := s.append(fileStoreRecord{Op: removeNodeOp, Hash: hash}); err != nil {
+		return err
+	}
+	if err := s.memory.RemoveNode(hash); err != nil {
+		return err
+	}
+	delete(s.specs, hash)
+	return nil
+}
+
+// isolated returns whether the node has no edges.
+func (s *FileStore) isolated(hash string) bool {
+	edges, _ := s.memory.ListEdges()
+	return !slices.ContainsFunc(edges, func(edge Edge) bool {
+		return edge.SourceHash == hash || edge.TargetHash == hash
+	})
+}
+
+func (s *FileStore) ListNodes() ([]string, error) {
+	return s.memory.ListNodes()
+}
+
+func (s *FileStore) NodeCount() (int, error) {
+	return s.memory.NodeCount()
+}
+
+func (s *FileStore) AddEdge(sourceHash, targetHash string, edge Edge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memory.Node(sourceHash); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	if _, err := s.memory.Node(targetHash); err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	if _, err := s.memory.Edge(sourceHash, targetHash); err == nil {
+		return ErrEdgeAlreadyExists
+	}
+
+	spec, err := newEdgeSpec(edge)
+	if err != nil {
+		return err
+	}
+	spec.Source, spec.Target = sourceHash, targetHash
+	if err := s.append(fileStoreRecord{Op: addEdgeOp, Edge: &spec}); err != nil {
+		return err
+	}
+	return s.memory.AddEdge(sourceHash, targetHash, edge)
+}
+
+func (s *FileStore) UpdateEdge(sourceHash string, targetHash string, edge Edge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memory.Edge(sourceHash, targetHash); err != nil {
+		return err
+	}
+
+	spec, err := newEdgeSpec(edge)
+	if err != nil {
+		return err
+	}
+	spec.Source, spec.Target = sourceHash, targetHash
+	if err := s.append(fileStoreRecord{Op: updateEdgeOp, Edge: &spec}); err != nil {
+		return err
+	}
+	return s.memory.UpdateEdge(sourceHash, targetHash, edge)
+}
+
+func (s *FileStore) RemoveEdge(sourceHash string, targetHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memory.Edge(sourceHash, targetHash); err != nil {
+		return nil
+	}
+	if err := s.append(fileStoreRecord{Op: removeEdgeOp, Source: sourceHash, Target: targetHash}); err != nil {
+		return err
+	}
+	return s.memory.RemoveEdge(sourceHash, targetHash)
+}
+
+func (s *FileStore) Edge(sourceHash string, targetHash string) (Edge, error) {
+	return s.memory.Edge(sourceHash, targetHash)
+}
+
+func (s *FileStore) ListEdges() ([]Edge, error) {
+	return s.memory.ListEdges()
+}
+
+func (s *FileStore) CreatesCycle(source, target string) (bool, error) {
+	return s.memory.CreatesCycle(source, target)
+}
+
+// Compact rewrites the file with only the records needed to rebuild the current graph, the log otherwise
+// keeps growing with every change. The file is replaced atomically.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("store %v is closed", s.path)
+	}
+
+	hashes, _ := s.memory.ListNodes()
+	slices.Sort(hashes)
+	edges, _ := s.memory.ListEdges()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, hash := range hashes {
+		spec := s.specs[hash]
+		if err := encoder.Encode(fileStoreRecord{Op: addNodeOp, Node: &spec}); err != nil {
+			return err
+		}
+	}
+	for _, edge := range edges {
+		spec, err := newEdgeSpec(edge)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(fileStoreRecord{Op: addEdgeOp, Edge: &spec}); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	// the renamed file is positioned at its end, ready for the next records
+	_ = s.file.Close()
+	s.file = tmp
+	return nil
+}
+
+// Close closes the file, the store can't be changed afterwards.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,157 @@
+package ringchain
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFileStore_Reopen(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	path := filepath.Join(t.TempDir(), "graph.jsonl")
+
+	store, err := OpenFileStore(path, registry)
+	require.NoError(t, err)
+	spec, err := ParseGraphSpec([]byte(testGraphSpec))
+	require.NoError(t, err)
+	// specs are loaded back as JSON values
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	var stored GraphSpec
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	g := NewGraph(WithStore(store))
+	for _, nodeSpec := range spec.Nodes {
+		node, err := registry.New(nodeSpec.Type, nodeSpec.Config)
+		require.NoError(t, err)
+		require.NoError(t, g.AddNode(nodeSpec.Name, node, nodeSpec.options()...))
+	}
+	for _, edgeSpec := range spec.Edges {
+		if edgeSpec.Loop == nil {
+			require.NoError(t, g.AddEdge(edgeSpec.Source, edgeSpec.Target, edgeSpec.options()...))
+		}
+	}
+	for _, edgeSpec := range spec.Edges {
+		if edgeSpec.Loop != nil {
+			require.NoError(t, g.AddEdge(edgeSpec.Source, edgeSpec.Target, edgeSpec.options()...))
+		}
+	}
+	assert.ErrorIs(t, g.AddNode("untyped", TestNode{name: "untyped"}), ErrNotExportable)
+	assert.ErrorIs(t, g.AddEdge("draft", "answer", WithCondition(WhenEquals("draft", 1))), ErrNotExportable)
+	expected, err := g.Execute(ctx, logger, map[string]any{"question": "why"})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	reopen := func(t *testing.T) (*FileStore, *Graph) {
+		store, err := OpenFileStore(path, registry)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, store.Close())
+		})
+		return store, NewGraph(WithStore(store))
+	}
+
+	t.Run("Reopen", func(t *testing.T) {
+		_, g := reopen(t)
+		exported, err := g.Spec()
+		require.NoError(t, err)
+		assert.Equal(t, stored, exported)
+
+		res, err := g.Execute(ctx, logger, map[string]any{"question": "why"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, res)
+	})
+
+	t.Run("PartialWrite", func(t *testing.T) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"op":"add_node","node":{"name":"torn"`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		store, g := reopen(t)
+		_, err = g.Node("torn")
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+
+		require.NoError(t, g.AddNode("extra", TestNode{name: "test"}, WithType("test", nil)))
+		require.NoError(t, store.Close())
+		_, g = reopen(t)
+		_, err = g.Node("extra")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		store, g := reopen(t)
+		require.NoError(t, store.RemoveNode("extra"))
+		before, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, store.Compact())
+		after, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Less(t, after.Size(), before.Size())
+
+		require.NoError(t, g.AddNode("extra", TestNode{name: "test"}, WithType("test", nil)))
+		require.NoError(t, store.Close())
+
+		_, g = reopen(t)
+		exported, err := g.Spec()
+		require.NoError(t, err)
+		assert.Len(t, exported.Nodes, len(spec.Nodes)+1)
+		assert.Equal(t, stored.Edges, exported.Edges)
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		_, err := OpenFileStore(path, NewRegistry())
+		assert.ErrorIs(t, err, ErrUnknownNodeType)
+	})
+}
+
+func TestFileStore_Replay(t *testing.T) {
+	registry := newTestRegistry(t)
+	path := filepath.Join(t.TempDir(), "graph.jsonl")
+
+	store, err := OpenFileStore(path, registry)
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.AddNode("untyped", TestNode{name: "untyped"}), ErrNotExportable)
+	for _, hash := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, store.AddNodeSpec(NodeSpec{Name: hash, Type: "test"}, TestNode{name: hash}))
+	}
+	require.NoError(t, store.AddEdge("a", "b", newEdge("a", "b", EdgeOptions{})))
+	require.NoError(t, store.AddEdge("a", "c", newEdge("a", "c", EdgeOptions{})))
+	require.NoError(t, store.AddEdge("b", "d", newEdge("b", "d", EdgeOptions{})))
+	updated := newEdge("a", "b", EdgeOptions{mappings: map[string]string{"summary": "context"}})
+	require.NoError(t, store.UpdateEdge("a", "b", updated))
+	require.NoError(t, store.RemoveEdge("a", "c"))
+	require.NoError(t, store.RemoveNode("c"))
+	require.NoError(t, store.Close())
+
+	// every kind of record is replayed
+	store, err = OpenFileStore(path, registry)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+
+	hashes, err := store.ListNodes()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "d"}, hashes)
+	spec, err := store.NodeSpec("d")
+	require.NoError(t, err)
+	assert.Equal(t, NodeSpec{Name: "d", Type: "test"}, spec)
+	_, err = store.NodeSpec("c")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+
+	edges, err := store.ListEdges()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Edge{updated, newEdge("b", "d", EdgeOptions{})}, edges)
+	_, err = store.Edge("a", "c")
+	assert.ErrorIs(t, err, ErrEdgeNotFound)
+}
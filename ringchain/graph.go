@@ -4,24 +4,113 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"maps"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 type Graph struct {
+	store       Store
+	nodeOptions map[string]NodeOptions
+	// err is the error that happened loading the nodes of the store, the graph can't be changed nor executed then
+	err error
+}
+
+type GraphOptions struct {
 	store Store
 }
 
-func NewGraph() *Graph {
-	return &Graph{
-		store: newMemoryStore(),
+// WithStore sets the store of the graph topology, an in-memory store is used by default.
+// Nodes already in the store are part of the graph, with the options they were added with for a SpecStore.
+// If they can't be loaded, the graph returns the error from every AddNode, AddEdge and execution.
+func WithStore(store Store) func(*GraphOptions) {
+	return func(opts *GraphOptions) {
+		opts.store = store
+	}
+}
+
+func NewGraph(opts ...func(*GraphOptions)) *Graph {
+	options := GraphOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.store == nil {
+		options.store = NewMemoryStore()
+	}
+
+	g := &Graph{
+		store:       options.store,
+		nodeOptions: make(map[string]NodeOptions),
 	}
+
+	// the error is returned by every change and execution of the graph, so nothing is added to a store that
+	// couldn't be read
+	g.err = g.loadNodeOptions()
+	return g
 }
 
-func (g *Graph) AddNode(name string, node Node) error {
+func (g *Graph) loadNodeOptions() error {
+	hashes, err := g.store.ListNodes()
+	if err != nil {
+		return fmt.Errorf("could not list the nodes of the store: %w", err)
+	}
+	specStore, isSpecStore := g.store.(SpecStore)
+	for _, hash := range hashes {
+		nodeOpts := []func(*NodeOptions){}
+		if isSpecStore {
+			spec, err := specStore.NodeSpec(hash)
+			if err != nil && !errors.Is(err, ErrNodeNotFound) {
+				return fmt.Errorf("could not load the spec of node %v: %w", hash, err)
+			} else if err == nil {
+				nodeOpts = spec.options()
+			}
+		}
+		g.nodeOptions[hash] = newNodeOptions(nodeOpts)
+	}
+	return nil
+}
+
+func newNodeOptions(opts []func(*NodeOptions)) NodeOptions {
+	options := NodeOptions{
+		join:      JoinAll,
+		inputMode: InputMerged,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// AddNode adds the node to the graph under its name. Nodes added to a SpecStore must have a type, see WithType.
+func (g *Graph) AddNode(name string, node Node, opts ...func(*NodeOptions)) error {
+	if g.err != nil {
+		return g.err
+	}
+	options := newNodeOptions(opts)
+	if options.join != JoinAll && options.join != JoinAny {
+		return fmt.Errorf("%w: %q", ErrUnknownJoin, options.join)
+	}
+	if options.inputMode != InputMerged && options.inputMode != InputNamespaced {
+		return fmt.Errorf("%w: %q", ErrUnknownInputMode, options.inputMode)
+	}
+	if options.maxAttempts < 0 || options.timeout < 0 {
+		return fmt.Errorf("max attempts and timeout can't be negative")
+	}
+
 	hash := name
-	return g.store.AddNode(hash, node)
+	if specStore, ok := g.store.(SpecStore); ok {
+		spec, err := newNodeSpec(hash, options)
+		if err != nil {
+			return err
+		}
+		if err := specStore.AddNodeSpec(spec, node); err != nil {
+			return err
+		}
+	} else if err := g.store.AddNode(hash, node); err != nil {
+		return err
+	}
+	g.nodeOptions[hash] = options
+	return nil
 }
 
 func (g *Graph) Node(hash string) (Node, error) {
@@ -29,7 +118,15 @@ func (g *Graph) Node(hash string) (Node, error) {
 	return node, err
 }
 
-func (g *Graph) AddEdge(sourceHash, targetHash string) error {
+func (g *Graph) AddEdge(sourceHash, targetHash string, opts ...func(*EdgeOptions)) error {
+	if g.err != nil {
+		return g.err
+	}
+	options := EdgeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	_, err := g.store.Node(sourceHash)
 	if err != nil {
 		return fmt.Errorf("source node %v: %w", sourceHash, err)
@@ -47,18 +144,34 @@ func (g *Graph) AddEdge(sourceHash, targetHash string) error {
 	createsCycle, err := g.createsCycle(sourceHash, targetHash)
 	if err != nil {
 		return fmt.Errorf("check for cycles: %w", err)
+	}
+	if options.loop != nil {
+		if err := g.validateLoop(sourceHash, targetHash, options, createsCycle); err != nil {
+			return err
+		}
 	} else if createsCycle {
 		return ErrEdgeCreatesCycle
 	}
 
-	edge := Edge{
-		SourceHash: sourceHash,
-		TargetHash: targetHash,
+	edge := newEdge(sourceHash, targetHash, options)
+	if err := g.validateInputs(edge); err != nil {
+		return err
 	}
 
 	return g.store.AddEdge(sourceHash, targetHash, edge)
 }
 
+func newEdge(sourceHash, targetHash string, options EdgeOptions) Edge {
+	return Edge{
+		SourceHash: sourceHash,
+		TargetHash: targetHash,
+		Condition:  options.condition,
+		When:       options.when,
+		Loop:       options.loop,
+		Mappings:   options.mappings,
+	}
+}
+
 func (g *Graph) Edge(sourceHash string, targetHash string) (Edge, error) {
 	edge, err := g.store.Edge(sourceHash, targetHash)
 	if err != nil {
@@ -119,12 +232,42 @@ func (g *Graph) PredecessorMap() (map[string]map[string]Edge, error) {
 	return m, nil
 }
 
+// validateLoop checks that a loop edge points back to an ancestor of its source.
+func (g *Graph) validateLoop(sourceHash, targetHash string, options EdgeOptions, backEdge bool) error {
+	if !backEdge {
+		return fmt.Errorf("%w: %v is not an ancestor of %v", ErrInvalidLoop, targetHash, sourceHash)
+	}
+	if options.condition != nil {
+		return fmt.Errorf("%w: loop edges can't have a condition, use until instead", ErrInvalidLoop)
+	}
+	if options.loop.MaxIterations < 1 {
+		return fmt.Errorf("%w: max iterations must be at least 1", ErrInvalidLoop)
+	}
+
+	successors, err := g.SuccessorMap()
+	if err != nil {
+		return err
+	}
+	for _, edge := range successors[sourceHash] {
+		if edge.Loop != nil {
+			return fmt.Errorf("%w: %v is already the tail of a loop", ErrInvalidLoop, sourceHash)
+		}
+	}
+	return nil
+}
+
 func (g *Graph) createsCycle(source, target string) (bool, error) {
 	return g.store.CreatesCycle(source, target)
 }
 
 type GraphExecuteOptions struct {
-	NumWorkers int
+	NumWorkers   int
+	Checkpointer Checkpointer
+	ExecutionID  string
+	EventHandler func(Event)
+
+	// rootArgs are the args of every root node, set by subgraphs with declared inputs
+	rootArgs map[string]map[string]any
 }
 
 func WithNumWorkers(n int) func(*GraphExecuteOptions) {
@@ -133,88 +276,98 @@ func WithNumWorkers(n int) func(*GraphExecuteOptions) {
 	}
 }
 
-// Execute executes the graph starting from the given node.
-// You need to call Init before calling Execute.
+// Execute executes the graph, every node runs once its predecessors are done with the merged results of its active
+// incoming edges as args. Nodes without predecessors run with the given args. Edges are inactive when their condition
+// is false, when a Router didn't route to them or when their source was skipped. A node without any active incoming
+// edge is skipped and doesn't appear in the results.
+// It returns the results of every node that ran by hash. Nodes inside a loop body are also recorded for every
+// iteration by their IterationHash. The first node error cancels the execution and is returned as is.
 // This method is safe to call concurrently but might break if the graph is modified while executing.
 func (g *Graph) Execute(ctx context.Context, logger *zap.Logger, args map[string]any, opts ...func(*GraphExecuteOptions)) (map[string]map[string]any, error) {
+	options := newGraphExecuteOptions(opts)
+	if options.ExecutionID == "" {
+		options.ExecutionID = newExecutionID()
+		if options.Checkpointer != nil {
+			logger.Info("checkpointing execution", zap.String("execution_id", options.ExecutionID))
+		}
+	}
+	results, err := g.execute(ctx, logger, args, options, nil)
+	return results, unwrapNodeError(err)
+}
+
+// Resume resumes the execution with the given id from its last checkpoint, the checkpointer must be set with
+// WithCheckpointer. Nodes that succeeded are not run again, their checkpointed results are reused.
+func (g *Graph) Resume(ctx context.Context, logger *zap.Logger, executionID string, opts ...func(*GraphExecuteOptions)) (map[string]map[string]any, error) {
+	options := newGraphExecuteOptions(opts)
+	if options.Checkpointer == nil {
+		return nil, ErrNoCheckpointer
+	}
+	options.ExecutionID = executionID
+
+	checkpoint, err := options.Checkpointer.Load(ctx, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load checkpoint %s: %w", executionID, err)
+	}
+	results, err := g.execute(ctx, logger, checkpoint.Args, options, checkpoint.Results)
+	return results, unwrapNodeError(err)
+}
+
+func newGraphExecuteOptions(opts []func(*GraphExecuteOptions)) GraphExecuteOptions {
 	options := GraphExecuteOptions{
 		NumWorkers: 10,
 	}
 	for _, opt := range opts {
 		opt(&options)
 	}
+	return options
+}
 
-	predecessorMap, err := g.PredecessorMap()
-	if err != nil {
+func (g *Graph) execute(ctx context.Context, logger *zap.Logger, args map[string]any, options GraphExecuteOptions, previous map[string]map[string]any) (map[string]map[string]any, error) {
+	emitter := &emitter{handler: options.EventHandler, executionID: options.ExecutionID}
+	// the execution is started before anything can fail so every finished event has a started event
+	start := time.Now()
+	emitter.emit(Event{Type: ExecutionStartedEventType, Input: args})
+	fail := func(err error) (map[string]map[string]any, error) {
+		emitter.emit(Event{Type: ExecutionFinishedEventType, Latency: time.Since(start), Error: err.Error()})
 		return nil, err
 	}
-	nodeCount, err := g.store.NodeCount()
-	if err != nil {
-		return nil, err
+	if g.err != nil {
+		return fail(g.err)
 	}
 
-	workerPool := newWorkerPool(options.NumWorkers, nodeCount)
-	if err := workerPool.Run(ctx); err != nil {
-		return nil, err
+	successorMap, err := g.SuccessorMap()
+	if err != nil {
+		return fail(err)
 	}
-
-	// add all nodes without predecessors to the worker pool
-	for nodeHash, predecessors := range predecessorMap {
-		if len(predecessors) == 0 {
-			node, err := g.Node(nodeHash)
-			if err != nil {
-				return nil, err
-			}
-			workerPool.AddNodeJob(logger, nodeHash, node, args)
-			delete(predecessorMap, nodeHash)
-		}
+	predecessorMap, err := g.PredecessorMap()
+	if err != nil {
+		return fail(err)
 	}
 
-	nodeResults := make(map[string]map[string]any, nodeCount)
-	nodeInputMap := make(map[string]map[string]any, nodeCount)
-
-	for workerPool.NumRunningNodes() > 0 {
-		runningNode := workerPool.PopRunningNode()
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case err := <-workerPool.errChan:
-			return nil, err
-		case res := <-runningNode.Result:
-
-			nodeResults[runningNode.NodeHash] = res
-			for nodeHash, predecessors := range predecessorMap {
-				// check for predecessors of the finished node
-				if _, ok := predecessors[runningNode.NodeHash]; ok {
-					if _, ok := nodeInputMap[nodeHash]; !ok {
-						nodeInputMap[nodeHash] = make(map[string]any)
-					}
-					// Save running node result in the next node input map
-					maps.Copy(nodeInputMap[nodeHash], res)
-					delete(predecessors, runningNode.NodeHash)
-				}
-
-				if len(predecessors) == 0 {
-					node, err := g.Node(nodeHash)
-					if err != nil {
-						return nil, err
-					}
-					input := nodeInputMap[nodeHash]
-
-					workerPool.AddNodeJob(logger, nodeHash, node, input)
-					delete(predecessorMap, nodeHash)
-				}
-			}
-		default:
-			// no result yet, put it back in the queue
-			workerPool.AppendRunningNode(runningNode)
-		}
+	execution, err := newExecution(g, logger, successorMap, predecessorMap, options)
+	if err != nil {
+		return fail(err)
 	}
+	execution.previous = previous
+	execution.emitter = emitter
 
-	workerPool.Stop()
-	if err := workerPool.Wait(); err != nil {
-		return nil, err
-	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	return nodeResults, nil
+	execution.workerPool = newWorkerPool(options.NumWorkers, len(predecessorMap), emitter)
+	execution.workerPool.Run(ctx)
+
+	results, err := execution.run(ctx, args)
+
+	// the queued jobs are dropped and the running ones canceled, no worker outlives the execution
+	// so no node event can be emitted after the execution finished event
+	cancel()
+	execution.workerPool.Stop()
+	execution.workerPool.Wait()
+
+	if err != nil {
+		return fail(err)
+	}
+	emitter.emit(Event{Type: ExecutionFinishedEventType, Latency: time.Since(start), Results: results})
+	return results, nil
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"maps"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -129,6 +132,111 @@ func TestGraph_Execute(t *testing.T) {
 	})
 }
 
+func TestGraph_ExecuteLarge(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// a root fanning out to thousands of nodes that all join into a sink
+	const width = 2000
+	g := NewGraph()
+	require.NoError(t, g.AddNode("root", TestNode{name: "root"}))
+	require.NoError(t, g.AddNode("sink", TestNode{name: "sink"}))
+	for w := range width {
+		hash := fmt.Sprintf("%d", w)
+		require.NoError(t, g.AddNode(hash, TestNode{name: hash}))
+		require.NoError(t, g.AddEdge("root", hash))
+		require.NoError(t, g.AddEdge(hash, "sink"))
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 50)
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := g.Execute(ctx, logger, map[string]any{}, WithNumWorkers(4))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(res) != width+2 || len(res["sink"]) != width+2 {
+					errs <- fmt.Errorf("unexpected results")
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+}
+
+type BlockingNode struct {
+	name string
+}
+
+func (n BlockingNode) Name() string {
+	return n.name
+}
+
+func (n BlockingNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGraph_ExecuteCanceled(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	g := NewGraph()
+	require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+	require.NoError(t, g.AddNode("2", BlockingNode{name: "2"}))
+	require.NoError(t, g.AddEdge("1", "2"))
+
+	_, err := g.Execute(ctx, logger, map[string]any{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// CountingNode counts its runs.
+type CountingNode struct {
+	name string
+	runs *atomic.Int64
+}
+
+func (n CountingNode) Name() string {
+	return n.name
+}
+
+func (n CountingNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	n.runs.Add(1)
+	time.Sleep(time.Millisecond)
+	return map[string]any{}, nil
+}
+
+func TestGraph_ExecuteJoinsWorkers(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	runs := &atomic.Int64{}
+	g := NewGraph()
+	require.NoError(t, g.AddNode("broken", ErrorNode{name: "broken"}))
+	for i := range 50 {
+		hash := fmt.Sprintf("root_%d", i)
+		require.NoError(t, g.AddNode(hash, CountingNode{name: hash, runs: runs}))
+	}
+
+	_, err := g.Execute(ctx, logger, map[string]any{}, WithNumWorkers(2))
+	require.Error(t, err)
+
+	// the jobs still queued when the execution failed are dropped, not run in the background
+	count := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, count, runs.Load())
+}
+
 type ErrorNode struct {
 	name string
 }
@@ -0,0 +1,182 @@
+package ringchain
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+type InputMode string
+
+const (
+	// InputMerged merges the results of the predecessors into a single map, it is the default.
+	InputMerged InputMode = "merged"
+	// InputNamespaced puts the result of every predecessor under its hash.
+	InputNamespaced InputMode = "namespaced"
+)
+
+// ArgsNamespace is the key the args of the execution are put under for namespaced nodes using WithArgs.
+const ArgsNamespace = "_args"
+
+// OutputDeclarer is implemented by nodes that declare the keys of their result ahead of time,
+// which lets AddEdge detect predecessors whose results would overwrite each other. Returning nil means the
+// outputs are not known.
+type OutputDeclarer interface {
+	Outputs() []string
+}
+
+// WithInputMode sets how the results of the predecessors of the node are combined into its args.
+// Nodes without predecessors always run with the args of the execution.
+func WithInputMode(mode InputMode) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.inputMode = mode
+	}
+}
+
+// WithArgs adds the args of the execution to the args of a node with predecessors. They are merged
+// first so results of the predecessors take precedence, or put under ArgsNamespace for namespaced nodes.
+func WithArgs() func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.withArgs = true
+	}
+}
+
+// WithMapping only passes the sourceKey of the result of the source node to the target node, as targetKey.
+// It can be used multiple times on an edge, the keys without a mapping are not passed.
+func WithMapping(sourceKey, targetKey string) func(*EdgeOptions) {
+	return func(opts *EdgeOptions) {
+		if opts.mappings == nil {
+			opts.mappings = make(map[string]string)
+		}
+		opts.mappings[sourceKey] = targetKey
+	}
+}
+
+// newInput returns the input a node starts with before the results of its predecessors are added.
+func newInput(options NodeOptions, args map[string]any) map[string]any {
+	input := make(map[string]any)
+	if options.withArgs {
+		if options.inputMode == InputNamespaced {
+			input[ArgsNamespace] = args
+		} else {
+			maps.Copy(input, args)
+		}
+	}
+	return input
+}
+
+// addInput adds the result of the source of the edge to the input of its target. Sources records the predecessor
+// that added each key of a merged input: AddEdge can only catch collisions between declared outputs, so a key
+// added by two predecessors with different values is an ErrInputCollision instead of being overwritten. Equal
+// values are fine, they are what the branches of a diamond pass along.
+func addInput(options NodeOptions, input map[string]any, sources map[string]string, edge Edge, result map[string]any) error {
+	if len(edge.Mappings) > 0 {
+		mapped := make(map[string]any, len(edge.Mappings))
+		for sourceKey, targetKey := range edge.Mappings {
+			if v, ok := result[sourceKey]; ok {
+				mapped[targetKey] = v
+			}
+		}
+		result = mapped
+	}
+
+	if options.inputMode == InputNamespaced {
+		input[edge.SourceHash] = result
+		return nil
+	}
+
+	collisions := make([]string, 0)
+	predecessor := ""
+	for key := range result {
+		if source, ok := sources[key]; ok && source != edge.SourceHash && !reflect.DeepEqual(input[key], result[key]) {
+			collisions = append(collisions, key)
+			predecessor = source
+		}
+	}
+	if len(collisions) > 0 {
+		slices.Sort(collisions)
+		return fmt.Errorf("%w: %v and %v both passed %s to %v, map them to other keys or use namespaced inputs",
+			ErrInputCollision, edge.SourceHash, predecessor, strings.Join(collisions, ", "), edge.TargetHash)
+	}
+	for key, v := range result {
+		input[key] = v
+		sources[key] = edge.SourceHash
+	}
+	return nil
+}
+
+// inputKeys returns the keys an edge adds to the input of its target, or false if they aren't known.
+func (g *Graph) inputKeys(edge Edge) ([]string, bool, error) {
+	if len(edge.Mappings) > 0 {
+		return slices.Collect(maps.Values(edge.Mappings)), true, nil
+	}
+
+	source, err := g.Node(edge.SourceHash)
+	if err != nil {
+		return nil, false, err
+	}
+	declarer, ok := source.(OutputDeclarer)
+	if !ok || declarer.Outputs() == nil {
+		return nil, false, nil
+	}
+	return declarer.Outputs(), true, nil
+}
+
+// validateInputs checks that a new edge doesn't map outputs its source doesn't declare, and that
+// the keys it adds to the merged input of its target aren't added by another edge already.
+func (g *Graph) validateInputs(edge Edge) error {
+	source, err := g.Node(edge.SourceHash)
+	if err != nil {
+		return err
+	}
+	if declarer, ok := source.(OutputDeclarer); ok && declarer.Outputs() != nil {
+		outputs := declarer.Outputs()
+		for sourceKey := range edge.Mappings {
+			if !slices.Contains(outputs, sourceKey) {
+				return fmt.Errorf("%w: %v doesn't declare %q", ErrUnknownOutput, edge.SourceHash, sourceKey)
+			}
+		}
+	}
+
+	options := g.nodeOptions[edge.TargetHash]
+	if edge.Loop != nil || options.inputMode == InputNamespaced || options.join == JoinAny {
+		return nil
+	}
+
+	keys, ok, err := g.inputKeys(edge)
+	if err != nil || !ok {
+		return err
+	}
+
+	predecessors, err := g.PredecessorMap()
+	if err != nil {
+		return err
+	}
+	for predecessor, other := range predecessors[edge.TargetHash] {
+		if other.Loop != nil {
+			continue
+		}
+		otherKeys, ok, err := g.inputKeys(other)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		collisions := make([]string, 0)
+		for _, key := range keys {
+			if slices.Contains(otherKeys, key) {
+				collisions = append(collisions, key)
+			}
+		}
+		if len(collisions) > 0 {
+			slices.Sort(collisions)
+			return fmt.Errorf("%w: %v and %v both pass %s to %v, map them to other keys or use namespaced inputs",
+				ErrInputCollision, edge.SourceHash, predecessor, strings.Join(collisions, ", "), edge.TargetHash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package ringchain
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// EchoNode returns its args.
+type EchoNode struct {
+	name string
+}
+
+func (n EchoNode) Name() string {
+	return n.name
+}
+
+func (n EchoNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	return maps.Clone(args), nil
+}
+
+// SummaryNode declares a summary output.
+type SummaryNode struct {
+	name string
+}
+
+func (n SummaryNode) Name() string {
+	return n.name
+}
+
+func (n SummaryNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	return map[string]any{"summary": "summary of " + n.name}, nil
+}
+
+func (n SummaryNode) Outputs() []string {
+	return []string{"summary"}
+}
+
+func TestGraph_ExecuteInputs(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("Namespaced", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", SummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", SummaryNode{name: "b"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}, WithInputMode(InputNamespaced), WithArgs()))
+		require.NoError(t, g.AddEdge("a", "target"))
+		require.NoError(t, g.AddEdge("b", "target"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{"question": "why"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"a":           map[string]any{"summary": "summary of a"},
+			"b":           map[string]any{"summary": "summary of b"},
+			ArgsNamespace: map[string]any{"question": "why"},
+		}, res["target"])
+	})
+
+	t.Run("Mappings", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", SummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", SummaryNode{name: "b"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}))
+		require.NoError(t, g.AddEdge("a", "target", WithMapping("summary", "context")))
+		require.NoError(t, g.AddEdge("b", "target"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{"question": "why"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"context": "summary of a", "summary": "summary of b"}, res["target"])
+	})
+
+	t.Run("Args", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", SummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}, WithArgs()))
+		require.NoError(t, g.AddEdge("a", "target"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{"question": "why", "summary": "overwritten"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"question": "why", "summary": "summary of a"}, res["target"])
+	})
+}
+
+func TestGraph_AddEdgeInputCollisions(t *testing.T) {
+	newGraph := func(opts ...func(*NodeOptions)) *Graph {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", SummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", SummaryNode{name: "b"}))
+		require.NoError(t, g.AddNode("undeclared", EchoNode{name: "undeclared"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}, opts...))
+		require.NoError(t, g.AddEdge("a", "target"))
+		return g
+	}
+
+	t.Run("Collision", func(t *testing.T) {
+		err := newGraph().AddEdge("b", "target")
+		assert.ErrorIs(t, err, ErrInputCollision)
+	})
+
+	t.Run("MappingCollision", func(t *testing.T) {
+		g := newGraph()
+		require.NoError(t, g.AddEdge("b", "target", WithMapping("summary", "context")))
+		err := g.AddEdge("undeclared", "target", WithMapping("anything", "context"))
+		assert.ErrorIs(t, err, ErrInputCollision)
+	})
+
+	t.Run("Resolved", func(t *testing.T) {
+		assert.NoError(t, newGraph().AddEdge("b", "target", WithMapping("summary", "b_summary")))
+		assert.NoError(t, newGraph(WithInputMode(InputNamespaced)).AddEdge("b", "target"))
+		assert.NoError(t, newGraph(WithJoin(JoinAny)).AddEdge("b", "target"))
+	})
+
+	t.Run("Undeclared", func(t *testing.T) {
+		assert.NoError(t, newGraph().AddEdge("undeclared", "target"))
+	})
+
+	t.Run("UnknownOutput", func(t *testing.T) {
+		err := newGraph().AddEdge("b", "target", WithMapping("answer", "b_answer"))
+		assert.ErrorIs(t, err, ErrUnknownOutput)
+	})
+
+	t.Run("UnknownInputMode", func(t *testing.T) {
+		err := NewGraph().AddNode("a", TestNode{name: "a"}, WithInputMode("some"))
+		assert.ErrorIs(t, err, ErrUnknownInputMode)
+	})
+}
+
+// UndeclaredSummaryNode returns the result of a SummaryNode without declaring it.
+type UndeclaredSummaryNode struct {
+	name string
+}
+
+func (n UndeclaredSummaryNode) Name() string {
+	return n.name
+}
+
+func (n UndeclaredSummaryNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	return SummaryNode(n).Run(ctx, logger, args)
+}
+
+func TestGraph_ExecuteInputCollisions(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("Collision", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", UndeclaredSummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", UndeclaredSummaryNode{name: "b"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}))
+		require.NoError(t, g.AddEdge("a", "target"))
+		// the outputs are not declared, the collision can only be detected once both results are known
+		require.NoError(t, g.AddEdge("b", "target"))
+
+		_, err := g.Execute(ctx, logger, map[string]any{})
+		assert.ErrorIs(t, err, ErrInputCollision)
+	})
+
+	t.Run("SameValue", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", UndeclaredSummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", EchoNode{name: "b"}))
+		require.NoError(t, g.AddNode("c", EchoNode{name: "c"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}))
+		require.NoError(t, g.AddEdge("a", "b"))
+		require.NoError(t, g.AddEdge("a", "c"))
+		require.NoError(t, g.AddEdge("b", "target"))
+		require.NoError(t, g.AddEdge("c", "target"))
+
+		// both branches of the diamond pass the summary of a along
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"summary": "summary of a"}, res["target"])
+	})
+
+	t.Run("Args", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", UndeclaredSummaryNode{name: "a"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}, WithArgs()))
+		require.NoError(t, g.AddEdge("a", "target"))
+
+		// results still take precedence over the args
+		res, err := g.Execute(ctx, logger, map[string]any{"summary": "overwritten"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"summary": "summary of a"}, res["target"])
+	})
+}
@@ -0,0 +1,134 @@
+package ringchain
+
+import (
+	"fmt"
+)
+
+// Loop makes an edge a back-edge from the tail of a loop to its head, the head must be an ancestor of the tail.
+// Every time the tail is done the head runs again with the result of the tail, until the Until condition is true
+// or the body ran MaxIterations times. The successors of the tail only run once the loop exits.
+//
+// A loop body is made of the nodes on the paths from the head to the tail. It can only be entered through its head
+// and exited through its tail.
+type Loop struct {
+	MaxIterations int
+	// Until stops the loop when it is true for the result of the tail, the loop runs MaxIterations times if it is nil
+	Until EdgeCondition
+	// UntilWhen is the declarative form of Until, it is only set by WithLoopUntil
+	UntilWhen *When
+}
+
+// WithLoop makes the edge a loop back-edge, see Loop. Loop edges are ignored by the cycle checks.
+func WithLoop(maxIterations int, until EdgeCondition) func(*EdgeOptions) {
+	return func(opts *EdgeOptions) {
+		opts.loop = &Loop{
+			MaxIterations: maxIterations,
+			Until:         until,
+		}
+	}
+}
+
+// WithLoopUntil makes the edge a loop back-edge that stops when the result value of the tail for key equals value.
+// Unlike WithLoop, the edge can be exported to a GraphSpec.
+func WithLoopUntil(maxIterations int, key string, value any) func(*EdgeOptions) {
+	return func(opts *EdgeOptions) {
+		when := &When{Key: key, Equals: value}
+		opts.loop = &Loop{
+			MaxIterations: maxIterations,
+			Until:         when.Condition(),
+			UntilWhen:     when,
+		}
+	}
+}
+
+// IterationHash returns the hash the result of the i-th run of a node inside a loop body is recorded with.
+func IterationHash(hash string, i int) string {
+	return fmt.Sprintf("%s[%d]", hash, i)
+}
+
+// loopState tracks a loop during an execution.
+type loopState struct {
+	head       string
+	tail       string
+	edge       Edge
+	loop       Loop
+	body       map[string]bool
+	iterations int
+	// restart is the result of the tail the head is restarted with, it is set while running nodes of the previous
+	// iteration are not done yet
+	restart map[string]any
+}
+
+// newLoopStates finds the body of every loop edge and checks that it can only be entered through its head and exited
+// through its tail.
+func newLoopStates(successorMap, predecessorMap map[string]map[string]Edge) ([]*loopState, error) {
+	loops := make([]*loopState, 0)
+	for source, successors := range successorMap {
+		for target, edge := range successors {
+			if edge.Loop == nil {
+				continue
+			}
+
+			forward := reachable(target, successorMap)
+			backward := reachable(source, predecessorMap)
+			body := make(map[string]bool)
+			for hash := range forward {
+				if backward[hash] {
+					body[hash] = true
+				}
+			}
+
+			for hash := range body {
+				for predecessor, e := range predecessorMap[hash] {
+					if e.Loop == nil && hash != target && !body[predecessor] {
+						return nil, fmt.Errorf("%w: %v enters the loop %v -> %v through %v instead of its head", ErrInvalidLoop, predecessor, source, target, hash)
+					}
+				}
+				for successor, e := range successorMap[hash] {
+					if e.Loop == nil && hash != source && !body[successor] {
+						return nil, fmt.Errorf("%w: %v exits the loop %v -> %v through %v instead of its tail", ErrInvalidLoop, successor, source, target, hash)
+					}
+				}
+			}
+
+			loops = append(loops, &loopState{
+				head: target,
+				tail: source,
+				edge: edge,
+				loop: *edge.Loop,
+				body: body,
+			})
+		}
+	}
+	return loops, nil
+}
+
+// reachable returns the nodes reachable from hash, including itself, without following loop edges.
+func reachable(hash string, adjacencyMap map[string]map[string]Edge) map[string]bool {
+	visited := map[string]bool{hash: true}
+	stack := newStack[string]()
+	stack.push(hash)
+	for !stack.isEmpty() {
+		current, _ := stack.pop()
+		for adjacency, edge := range adjacencyMap[current] {
+			if edge.Loop != nil || visited[adjacency] {
+				continue
+			}
+			visited[adjacency] = true
+			stack.push(adjacency)
+		}
+	}
+	return visited
+}
+
+// continues returns whether the loop runs again after its tail is done with result.
+func (l *loopState) continues(result map[string]any, routed map[string]bool) bool {
+	l.iterations++
+	if result == nil || (routed != nil && !routed[l.head]) {
+		return false
+	}
+	if l.iterations >= l.loop.MaxIterations {
+		return false
+	}
+	return l.loop.Until == nil || !l.loop.Until(result)
+}
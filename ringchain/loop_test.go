@@ -0,0 +1,157 @@
+package ringchain
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+type CounterNode struct {
+	name string
+}
+
+func (n CounterNode) Name() string {
+	return n.name
+}
+
+func (n CounterNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	result := maps.Clone(args)
+	count, _ := result[n.name].(int)
+	result[n.name] = count + 1
+	return result, nil
+}
+
+func TestGraph_ExecuteLoop(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	// draft -> critique -> publish, critique loops back to draft
+	newGraph := func(maxIterations int, until EdgeCondition) *Graph {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("start", TestNode{name: "start"}))
+		require.NoError(t, g.AddNode("draft", CounterNode{name: "draft"}))
+		require.NoError(t, g.AddNode("critique", CounterNode{name: "critique"}))
+		require.NoError(t, g.AddNode("publish", TestNode{name: "publish"}))
+		require.NoError(t, g.AddEdge("start", "draft"))
+		require.NoError(t, g.AddEdge("draft", "critique"))
+		require.NoError(t, g.AddEdge("critique", "publish"))
+		require.NoError(t, g.AddEdge("critique", "draft", WithLoop(maxIterations, until)))
+		return g
+	}
+
+	t.Run("Until", func(t *testing.T) {
+		res, err := newGraph(5, WhenEquals("critique", 3)).Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+
+		require.Contains(t, res, "publish")
+		assert.Equal(t, 3, res["publish"]["draft"])
+		assert.Equal(t, true, res["publish"]["n_start"])
+		for i := range 3 {
+			require.Contains(t, res, IterationHash("draft", i))
+			assert.Equal(t, i+1, res[IterationHash("draft", i)]["draft"])
+		}
+		assert.NotContains(t, res, IterationHash("draft", 3))
+		assert.NotContains(t, res, IterationHash("start", 0))
+		assert.Equal(t, res[IterationHash("critique", 2)], res["critique"])
+	})
+
+	t.Run("MaxIterations", func(t *testing.T) {
+		res, err := newGraph(2, nil).Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, res["publish"]["critique"])
+	})
+
+	t.Run("SelfLoop", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("retry", CounterNode{name: "retry"}))
+		require.NoError(t, g.AddEdge("retry", "retry", WithLoop(4, nil)))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, res["retry"]["retry"])
+		assert.Contains(t, res, IterationHash("retry", 3))
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		// outer -> inner, inner loops on itself 2 times for every one of the 3 outer iterations
+		g := NewGraph()
+		require.NoError(t, g.AddNode("outer", CounterNode{name: "outer"}))
+		require.NoError(t, g.AddNode("inner", CounterNode{name: "inner"}))
+		require.NoError(t, g.AddNode("end", TestNode{name: "end"}))
+		require.NoError(t, g.AddEdge("outer", "inner"))
+		require.NoError(t, g.AddEdge("inner", "end"))
+		require.NoError(t, g.AddEdge("inner", "inner", WithLoop(2, nil)))
+		require.NoError(t, g.AddEdge("end", "outer", WithLoop(3, nil)))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, res["end"]["outer"])
+		assert.Equal(t, 6, res["end"]["inner"])
+	})
+
+	t.Run("Router", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("a", TestNode{name: "a"}))
+		require.NoError(t, g.AddNode("router", RouterNode{name: "router", routes: []string{"b"}}))
+		require.NoError(t, g.AddNode("b", TestNode{name: "b"}))
+		require.NoError(t, g.AddEdge("a", "router"))
+		require.NoError(t, g.AddEdge("router", "b"))
+		require.NoError(t, g.AddEdge("router", "a", WithLoop(5, nil)))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Contains(t, res, "b")
+		assert.NotContains(t, res, IterationHash("a", 1), "router didn't route to the loop head")
+	})
+}
+
+func TestGraph_AddLoopEdge(t *testing.T) {
+	newGraph := func() *Graph {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+		require.NoError(t, g.AddNode("2", TestNode{name: "2"}))
+		require.NoError(t, g.AddNode("3", TestNode{name: "3"}))
+		require.NoError(t, g.AddEdge("1", "2"))
+		require.NoError(t, g.AddEdge("2", "3"))
+		return g
+	}
+
+	t.Run("NotBackEdge", func(t *testing.T) {
+		err := newGraph().AddEdge("1", "3", WithLoop(3, nil))
+		assert.ErrorIs(t, err, ErrInvalidLoop)
+	})
+
+	t.Run("MaxIterations", func(t *testing.T) {
+		err := newGraph().AddEdge("3", "1", WithLoop(0, nil))
+		assert.ErrorIs(t, err, ErrInvalidLoop)
+	})
+
+	t.Run("Condition", func(t *testing.T) {
+		err := newGraph().AddEdge("3", "1", WithLoop(3, nil), WithCondition(WhenEquals("a", 1)))
+		assert.ErrorIs(t, err, ErrInvalidLoop)
+	})
+
+	t.Run("CyclesStillRejected", func(t *testing.T) {
+		g := newGraph()
+		require.NoError(t, g.AddEdge("3", "1", WithLoop(3, nil)))
+		assert.ErrorIs(t, g.AddEdge("3", "2"), ErrEdgeCreatesCycle)
+		require.NoError(t, g.AddNode("4", TestNode{name: "4"}))
+		require.NoError(t, g.AddEdge("3", "4"))
+		assert.ErrorIs(t, g.AddEdge("4", "1"), ErrEdgeCreatesCycle)
+	})
+
+	t.Run("SecondExit", func(t *testing.T) {
+		g := newGraph()
+		require.NoError(t, g.AddEdge("3", "1", WithLoop(3, nil)))
+		require.NoError(t, g.AddNode("4", TestNode{name: "4"}))
+		require.NoError(t, g.AddEdge("2", "4"))
+
+		_, err := g.Execute(context.Background(), zaptest.NewLogger(t), map[string]any{})
+		assert.ErrorIs(t, err, ErrInvalidLoop)
+	})
+}
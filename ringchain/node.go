@@ -2,6 +2,7 @@ package ringchain
 
 import (
 	"context"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -14,7 +15,36 @@ type Node interface {
 	Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error)
 }
 
+type NodeOptions struct {
+	join      JoinType
+	inputMode InputMode
+	withArgs  bool
+
+	maxAttempts int
+	backoff     Backoff
+	timeout     time.Duration
+	retryable   func(error) bool
+	fallback    Node
+	// defaultOutput is used when the node and its fallback failed
+	defaultOutput map[string]any
+
+	// nodeType and config are the registry type the node was built from
+	nodeType string
+	config   map[string]any
+	// retry is the declarative form of maxAttempts and backoff
+	retry *RetrySpec
+}
+
 type Edge struct {
 	SourceHash string
 	TargetHash string
+	// Condition is nil for edges that are always active
+	Condition EdgeCondition
+	// When is the declarative form of Condition, it is only set by WithWhen
+	When *When
+	// Loop is only set for loop back-edges
+	Loop *Loop
+	// Mappings rename the keys of the source result passed to the target, from source key to target key.
+	// Only the mapped keys are passed when it is set.
+	Mappings map[string]string
 }
@@ -0,0 +1,133 @@
+package ringchain
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Backoff returns how long to wait before the given retry, starting at 1.
+type Backoff func(retry int) time.Duration
+
+// ExponentialBackoff doubles the wait between retries, starting at minWait and capped at maxWait.
+func ExponentialBackoff(minWait, maxWait time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		wait := minWait
+		for i := 1; i < retry && wait < maxWait; i++ {
+			wait *= 2
+		}
+		return min(wait, maxWait)
+	}
+}
+
+// WithRetry runs the node up to maxAttempts times when it fails with a retryable error, waiting for the backoff
+// between attempts. A nil backoff retries right away.
+func WithRetry(maxAttempts int, backoff Backoff) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.maxAttempts = maxAttempts
+		opts.backoff = backoff
+		opts.retry = nil
+	}
+}
+
+// WithTimeout cancels the context of every attempt of the node after the timeout. Attempts that time out are retried.
+// The fallback of the node, if any, gets the same timeout.
+func WithTimeout(timeout time.Duration) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithRetryable sets which errors of the node are retried, every error is retried by default.
+// Errors are never retried once the context of the execution is done.
+func WithRetryable(retryable func(error) bool) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.retryable = retryable
+	}
+}
+
+// WithFallback runs the fallback node with the same args when the node still fails after its last attempt.
+func WithFallback(fallback Node) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.fallback = fallback
+	}
+}
+
+// WithDefaultOutput uses output as the result of the node when it still fails after its last attempt and its fallback, if any, failed too.
+func WithDefaultOutput(output map[string]any) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.defaultOutput = output
+	}
+}
+
+// runNode runs the node of the job with the retry, timeout and fallback policies of its options.
+// It returns the result along with the number of attempts made.
+func runNode(ctx context.Context, job nodeJob, emitter *emitter) (map[string]any, int, error) {
+	node, options, logger := job.Node, job.Options, job.Logger
+	ctx = withNodeScope(ctx, job.NodeHash, emitter)
+
+	res, attempts, err := runAttempts(ctx, job, emitter)
+	if err == nil || ctx.Err() != nil {
+		return res, attempts, err
+	}
+
+	if options.fallback != nil {
+		logger.Warn("node failed, running its fallback", zap.String("node", node.Name()), zap.Error(err))
+		// the fallback gets the same timeout as an attempt so it can't block the execution either
+		res, err = runAttempt(ctx, logger, options.fallback, options.timeout, job.Args)
+		if err == nil || ctx.Err() != nil {
+			return res, attempts, err
+		}
+	}
+
+	if options.defaultOutput != nil {
+		logger.Warn("node failed, using its default output", zap.String("node", node.Name()), zap.Error(err))
+		return maps.Clone(options.defaultOutput), attempts, nil
+	}
+	return nil, attempts, err
+}
+
+func runAttempts(ctx context.Context, job nodeJob, emitter *emitter) (map[string]any, int, error) {
+	node, options, logger := job.Node, job.Options, job.Logger
+
+	for attempt := 1; ; attempt++ {
+		emitter.emit(Event{Type: NodeStartedEventType, NodeHash: job.NodeHash, Input: job.Args, Attempt: attempt})
+		res, err := runAttempt(ctx, logger, node, options.timeout, job.Args)
+		if err == nil {
+			return res, attempt, nil
+		}
+
+		if attempt >= options.maxAttempts || ctx.Err() != nil {
+			return nil, attempt, err
+		}
+		if options.retryable != nil && !options.retryable(err) {
+			return nil, attempt, err
+		}
+
+		var wait time.Duration
+		if options.backoff != nil {
+			wait = options.backoff(attempt)
+		}
+		logger.Debug("retrying node", zap.String("node", node.Name()), zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+		emitter.emit(Event{Type: NodeRetryingEventType, NodeHash: job.NodeHash, Attempt: attempt, Error: err.Error()})
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func runAttempt(ctx context.Context, logger *zap.Logger, node Node, timeout time.Duration, args map[string]any) (map[string]any, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return node.Run(ctx, logger, args)
+}
@@ -0,0 +1,116 @@
+package ringchain
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+var errTransient = errors.New("transient")
+
+type FlakyNode struct {
+	name     string
+	failures int32
+	attempts *atomic.Int32
+}
+
+func (n FlakyNode) Name() string {
+	return n.name
+}
+
+func (n FlakyNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	if n.attempts.Add(1) <= n.failures {
+		return nil, errTransient
+	}
+	return map[string]any{"n_" + n.name: true}, nil
+}
+
+func TestGraph_ExecuteNodePolicies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	execute := func(t *testing.T, node Node, opts ...func(*NodeOptions)) (map[string]map[string]any, error) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("node", node, opts...))
+		require.NoError(t, g.AddNode("next", TestNode{name: "next"}))
+		require.NoError(t, g.AddEdge("node", "next"))
+		return g.Execute(ctx, logger, map[string]any{})
+	}
+
+	t.Run("NoRetry", func(t *testing.T) {
+		attempts := &atomic.Int32{}
+		_, err := execute(t, FlakyNode{name: "flaky", failures: 1, attempts: attempts})
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		attempts := &atomic.Int32{}
+		res, err := execute(t, FlakyNode{name: "flaky", failures: 2, attempts: attempts}, WithRetry(3, ExponentialBackoff(time.Millisecond, 2*time.Millisecond)))
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), attempts.Load())
+		assert.Contains(t, res["next"], "n_flaky")
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		attempts := &atomic.Int32{}
+		_, err := execute(t, FlakyNode{name: "flaky", failures: 5, attempts: attempts}, WithRetry(3, nil))
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		attempts := &atomic.Int32{}
+		_, err := execute(t, FlakyNode{name: "flaky", failures: 5, attempts: attempts}, WithRetry(3, nil), WithRetryable(func(err error) bool {
+			return !errors.Is(err, errTransient)
+		}))
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := execute(t, BlockingNode{name: "blocking"}, WithRetry(2, nil), WithTimeout(10*time.Millisecond))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		res, err := execute(t, ErrorNode{name: "broken"}, WithRetry(2, nil), WithFallback(TestNode{name: "fallback"}))
+		require.NoError(t, err)
+		assert.Contains(t, res["node"], "n_fallback")
+		assert.Contains(t, res["next"], "n_fallback")
+	})
+
+	t.Run("FallbackTimeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := execute(t, ErrorNode{name: "broken"}, WithTimeout(10*time.Millisecond), WithFallback(BlockingNode{name: "fallback"}))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+
+		res, err := execute(t, ErrorNode{name: "broken"}, WithTimeout(10*time.Millisecond), WithFallback(BlockingNode{name: "fallback"}), WithDefaultOutput(map[string]any{"answer": "unknown"}))
+		require.NoError(t, err)
+		assert.Equal(t, "unknown", res["next"]["answer"])
+	})
+
+	t.Run("DefaultOutput", func(t *testing.T) {
+		res, err := execute(t, ErrorNode{name: "broken"}, WithFallback(ErrorNode{name: "fallback"}), WithDefaultOutput(map[string]any{"answer": "unknown"}))
+		require.NoError(t, err)
+		assert.Equal(t, "unknown", res["next"]["answer"])
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, backoff(1))
+	assert.Equal(t, 20*time.Millisecond, backoff(2))
+	assert.Equal(t, 40*time.Millisecond, backoff(3))
+	assert.Equal(t, 50*time.Millisecond, backoff(4))
+}
@@ -0,0 +1,74 @@
+package ringchain
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// NodeConstructor builds a node from the config of a NodeSpec.
+type NodeConstructor func(config map[string]any) (Node, error)
+
+// Registry maps the node types of graph specs to their constructors. It is safe for concurrent use.
+type Registry struct {
+	mu           sync.RWMutex
+	constructors map[string]NodeConstructor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		constructors: make(map[string]NodeConstructor),
+	}
+}
+
+// Register adds a node type, it fails with ErrNodeTypeExists if the type is already registered.
+func (r *Registry) Register(nodeType string, constructor NodeConstructor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.constructors[nodeType]; ok {
+		return fmt.Errorf("%w: %q", ErrNodeTypeExists, nodeType)
+	}
+	r.constructors[nodeType] = constructor
+	return nil
+}
+
+// Has returns whether the node type is registered.
+func (r *Registry) Has(nodeType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.constructors[nodeType]
+	return ok
+}
+
+// Types returns the registered node types, sorted.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.constructors))
+}
+
+// New builds a node of the given type, it fails with ErrUnknownNodeType if the type isn't registered.
+func (r *Registry) New(nodeType string, config map[string]any) (Node, error) {
+	r.mu.RLock()
+	constructor, ok := r.constructors[nodeType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownNodeType, nodeType)
+	}
+
+	node, err := constructor(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not build %q node: %w", nodeType, err)
+	}
+	return node, nil
+}
+
+// WithType records the registry type and config the node was built from so Graph.Spec can export it.
+// It is set by NewGraphFromSpec, and should be set on nodes added by hand to graphs that are exported.
+func WithType(nodeType string, config map[string]any) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.nodeType = nodeType
+		opts.config = config
+	}
+}
@@ -0,0 +1,99 @@
+package ringchain
+
+import (
+	"reflect"
+)
+
+// EdgeCondition decides from the result of the source node whether the edge is active.
+// The target of an inactive edge doesn't receive the result of the source.
+type EdgeCondition func(result map[string]any) bool
+
+// WhenEquals returns a condition that is true when the result value for key equals value.
+// Numbers are compared by value whatever their type, so 1 equals 1.0.
+func WhenEquals(key string, value any) EdgeCondition {
+	return func(result map[string]any) bool {
+		v, ok := result[key]
+		if !ok {
+			return false
+		}
+		if a, ok := toFloat(v); ok {
+			b, ok := toFloat(value)
+			return ok && a == b
+		}
+		return reflect.DeepEqual(v, value)
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// When is a condition on the result of a node that can be written in a GraphSpec: it is true when the
+// result value for Key equals Equals.
+type When struct {
+	Key    string `json:"key" yaml:"key"`
+	Equals any    `json:"equals" yaml:"equals"`
+}
+
+func (w When) Condition() EdgeCondition {
+	return WhenEquals(w.Key, w.Equals)
+}
+
+type EdgeOptions struct {
+	condition EdgeCondition
+	when      *When
+	loop      *Loop
+	mappings  map[string]string
+}
+
+// WithCondition only activates the edge when the condition is true for the result of the source node.
+func WithCondition(condition EdgeCondition) func(*EdgeOptions) {
+	return func(opts *EdgeOptions) {
+		opts.condition = condition
+		opts.when = nil
+	}
+}
+
+// WithWhen only activates the edge when the result value for key equals value. Unlike WithCondition,
+// the edge can be exported to a GraphSpec.
+func WithWhen(key string, value any) func(*EdgeOptions) {
+	return func(opts *EdgeOptions) {
+		opts.when = &When{Key: key, Equals: value}
+		opts.condition = opts.when.Condition()
+	}
+}
+
+// Router is a Node that decides at runtime which of its successors are activated.
+type Router interface {
+	Node
+
+	// Route returns the hashes of the successors to activate given the result of Run, the other successors are skipped.
+	// Every returned hash must be a successor of the router.
+	Route(result map[string]any) ([]string, error)
+}
+
+type JoinType string
+
+const (
+	// JoinAll runs the node once every predecessor is done, with the merged results of the active ones.
+	JoinAll JoinType = "all"
+	// JoinAny runs the node with the result of the first active predecessor, the others are ignored.
+	JoinAny JoinType = "any"
+)
+
+// WithJoin sets how the node waits for its predecessors, it defaults to JoinAll.
+// In both cases the node is skipped if none of its incoming edges is active.
+func WithJoin(join JoinType) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.join = join
+	}
+}
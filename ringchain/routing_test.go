@@ -0,0 +1,214 @@
+package ringchain
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+type ClassifierNode struct {
+	name  string
+	class string
+}
+
+func (n ClassifierNode) Name() string {
+	return n.name
+}
+
+func (n ClassifierNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	result := maps.Clone(args)
+	result["class"] = n.class
+	return result, nil
+}
+
+type RouterNode struct {
+	name   string
+	routes []string
+}
+
+func (n RouterNode) Name() string {
+	return n.name
+}
+
+func (n RouterNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	result := maps.Clone(args)
+	result["n_"+n.name] = true
+	return result, nil
+}
+
+func (n RouterNode) Route(result map[string]any) ([]string, error) {
+	return n.routes, nil
+}
+
+func TestGraph_ExecuteConditionalEdges(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	// classify -> (question | complaint) -> answer
+	g := NewGraph()
+	require.NoError(t, g.AddNode("classify", ClassifierNode{name: "classify", class: "question"}))
+	require.NoError(t, g.AddNode("question", TestNode{name: "question"}))
+	require.NoError(t, g.AddNode("complaint", TestNode{name: "complaint"}))
+	require.NoError(t, g.AddNode("escalate", TestNode{name: "escalate"}))
+	require.NoError(t, g.AddNode("answer", TestNode{name: "answer"}))
+
+	require.NoError(t, g.AddEdge("classify", "question", WithCondition(WhenEquals("class", "question"))))
+	require.NoError(t, g.AddEdge("classify", "complaint", WithCondition(WhenEquals("class", "complaint"))))
+	require.NoError(t, g.AddEdge("complaint", "escalate"))
+	require.NoError(t, g.AddEdge("question", "answer"))
+	require.NoError(t, g.AddEdge("escalate", "answer"))
+
+	res, err := g.Execute(ctx, logger, map[string]any{})
+	require.NoError(t, err)
+
+	assert.NotContains(t, res, "complaint")
+	assert.NotContains(t, res, "escalate", "skip should be propagated")
+	require.Contains(t, res, "answer")
+	assert.Contains(t, res["answer"], "n_question")
+	assert.NotContains(t, res["answer"], "n_escalate")
+}
+
+func TestGraph_ExecuteRouter(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("Routes", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("router", RouterNode{name: "router", routes: []string{"b"}}))
+		require.NoError(t, g.AddNode("a", TestNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", TestNode{name: "b"}))
+		require.NoError(t, g.AddEdge("router", "a"))
+		require.NoError(t, g.AddEdge("router", "b"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.NotContains(t, res, "a")
+		require.Contains(t, res, "b")
+		assert.Contains(t, res["b"], "n_router")
+	})
+
+	t.Run("NoRoutes", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("router", RouterNode{name: "router"}))
+		require.NoError(t, g.AddNode("a", TestNode{name: "a"}))
+		require.NoError(t, g.AddEdge("router", "a"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Contains(t, res, "router")
+		assert.NotContains(t, res, "a")
+	})
+
+	t.Run("InvalidRoute", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("router", RouterNode{name: "router", routes: []string{"c"}}))
+		require.NoError(t, g.AddNode("a", TestNode{name: "a"}))
+		require.NoError(t, g.AddEdge("router", "a"))
+
+		_, err := g.Execute(ctx, logger, map[string]any{})
+		assert.ErrorIs(t, err, ErrInvalidRoute)
+	})
+}
+
+func TestGraph_ExecuteJoin(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	newGraph := func(join JoinType) *Graph {
+		// router -> (a | b -> c) -> join
+		g := NewGraph()
+		require.NoError(t, g.AddNode("router", RouterNode{name: "router", routes: []string{"a", "b"}}))
+		require.NoError(t, g.AddNode("a", TestNode{name: "a"}))
+		require.NoError(t, g.AddNode("b", TestNode{name: "b"}))
+		require.NoError(t, g.AddNode("c", BlockingNode{name: "c"}))
+		require.NoError(t, g.AddNode("join", TestNode{name: "join"}, WithJoin(join)))
+		require.NoError(t, g.AddEdge("router", "a"))
+		require.NoError(t, g.AddEdge("router", "b"))
+		require.NoError(t, g.AddEdge("b", "c", WithCondition(WhenEquals("missing", true))))
+		require.NoError(t, g.AddEdge("a", "join"))
+		require.NoError(t, g.AddEdge("c", "join"))
+		return g
+	}
+
+	t.Run("All", func(t *testing.T) {
+		res, err := newGraph(JoinAll).Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.NotContains(t, res, "c")
+		require.Contains(t, res, "join")
+		assert.Contains(t, res["join"], "n_a")
+		assert.NotContains(t, res["join"], "n_b")
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		// slow only finishes once first ran, which never happens if first waits for it
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		firstRan := make(chan struct{})
+
+		g := NewGraph()
+		require.NoError(t, g.AddNode("fast", TestNode{name: "fast"}))
+		require.NoError(t, g.AddNode("slow", WaitNode{name: "slow", wait: firstRan}))
+		require.NoError(t, g.AddNode("first", SignalNode{name: "first", signal: firstRan}, WithJoin(JoinAny)))
+		require.NoError(t, g.AddEdge("fast", "first"))
+		require.NoError(t, g.AddEdge("slow", "first"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		assert.Contains(t, res, "slow")
+		require.Contains(t, res, "first")
+		assert.Contains(t, res["first"], "n_fast")
+		assert.NotContains(t, res["first"], "n_slow")
+	})
+
+	t.Run("AnySkipped", func(t *testing.T) {
+		res, err := newGraph(JoinAny).Execute(ctx, logger, map[string]any{})
+		require.NoError(t, err)
+		require.Contains(t, res, "join")
+		assert.Contains(t, res["join"], "n_a")
+	})
+
+	t.Run("UnknownJoin", func(t *testing.T) {
+		err := NewGraph().AddNode("a", TestNode{name: "a"}, WithJoin("some"))
+		assert.ErrorIs(t, err, ErrUnknownJoin)
+	})
+}
+
+type WaitNode struct {
+	name string
+	wait <-chan struct{}
+}
+
+func (n WaitNode) Name() string {
+	return n.name
+}
+
+func (n WaitNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.wait:
+	}
+	return map[string]any{"n_" + n.name: true}, nil
+}
+
+type SignalNode struct {
+	name   string
+	signal chan<- struct{}
+}
+
+func (n SignalNode) Name() string {
+	return n.name
+}
+
+func (n SignalNode) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	close(n.signal)
+	result := maps.Clone(args)
+	result["n_"+n.name] = true
+	return result, nil
+}
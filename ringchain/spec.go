@@ -0,0 +1,391 @@
+package ringchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GraphSpec is the declarative form of a graph, it can be written in YAML or JSON:
+//
+//	nodes:
+//	  - name: classify
+//	    type: classifier
+//	    config:
+//	      labels: [question, complaint]
+//	  - name: answer
+//	    type: llm
+//	    retry: {max_attempts: 3, min_backoff: 1s, max_backoff: 10s}
+//	    timeout: 30s
+//	edges:
+//	  - source: classify
+//	    target: answer
+//	    when: {key: class, equals: question}
+//	    mappings: {text: question}
+//
+// Node types are resolved with a Registry.
+type GraphSpec struct {
+	Nodes []NodeSpec `json:"nodes" yaml:"nodes"`
+	Edges []EdgeSpec `json:"edges,omitempty" yaml:"edges,omitempty"`
+}
+
+type NodeSpec struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is the name the node constructor is registered with
+	Type string `json:"type" yaml:"type"`
+	// Config is passed to the node constructor
+	Config        map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+	Join          JoinType       `json:"join,omitempty" yaml:"join,omitempty"`
+	InputMode     InputMode      `json:"input_mode,omitempty" yaml:"input_mode,omitempty"`
+	WithArgs      bool           `json:"with_args,omitempty" yaml:"with_args,omitempty"`
+	Retry         *RetrySpec     `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Timeout       string         `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	DefaultOutput map[string]any `json:"default_output,omitempty" yaml:"default_output,omitempty"`
+}
+
+// RetrySpec retries a node with an exponential backoff, see WithRetry.
+type RetrySpec struct {
+	MaxAttempts int    `json:"max_attempts" yaml:"max_attempts"`
+	MinBackoff  string `json:"min_backoff,omitempty" yaml:"min_backoff,omitempty"`
+	MaxBackoff  string `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+}
+
+type EdgeSpec struct {
+	Source   string            `json:"source" yaml:"source"`
+	Target   string            `json:"target" yaml:"target"`
+	Mappings map[string]string `json:"mappings,omitempty" yaml:"mappings,omitempty"`
+	When     *When             `json:"when,omitempty" yaml:"when,omitempty"`
+	Loop     *LoopSpec         `json:"loop,omitempty" yaml:"loop,omitempty"`
+}
+
+type LoopSpec struct {
+	MaxIterations int   `json:"max_iterations" yaml:"max_iterations"`
+	Until         *When `json:"until,omitempty" yaml:"until,omitempty"`
+}
+
+// ParseGraphSpec parses a YAML or JSON graph spec. Unknown fields are rejected.
+func ParseGraphSpec(data []byte) (GraphSpec, error) {
+	var spec GraphSpec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return GraphSpec{}, fmt.Errorf("could not parse graph spec: %w", err)
+	}
+	return spec, nil
+}
+
+// LoadGraph reads the graph spec at path and builds the graph with the node types of the registry.
+func LoadGraph(path string, registry *Registry) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseGraphSpec(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphFromSpec(spec, registry)
+}
+
+func parseDuration(field string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s: can't be negative", field)
+	}
+	return d, nil
+}
+
+// Validate checks the spec without building it, and returns all the problems it finds.
+// Cycles and input collisions are only detected when the graph is built.
+func (s GraphSpec) Validate(registry *Registry) error {
+	errs := make([]error, 0)
+	names := make(map[string]bool, len(s.Nodes))
+	for i, node := range s.Nodes {
+		nodeErr := func(err error) {
+			errs = append(errs, fmt.Errorf("node %d %q: %w", i, node.Name, err))
+		}
+
+		if node.Name == "" {
+			nodeErr(fmt.Errorf("missing name"))
+		} else if names[node.Name] {
+			nodeErr(ErrNodeAlreadyExists)
+		}
+		names[node.Name] = true
+
+		if !registry.Has(node.Type) {
+			nodeErr(fmt.Errorf("%w: %q", ErrUnknownNodeType, node.Type))
+		}
+		if node.Join != "" && node.Join != JoinAll && node.Join != JoinAny {
+			nodeErr(fmt.Errorf("%w: %q", ErrUnknownJoin, node.Join))
+		}
+		if node.InputMode != "" && node.InputMode != InputMerged && node.InputMode != InputNamespaced {
+			nodeErr(fmt.Errorf("%w: %q", ErrUnknownInputMode, node.InputMode))
+		}
+		if _, err := parseDuration("timeout", node.Timeout); err != nil {
+			nodeErr(err)
+		}
+		if node.Retry != nil {
+			if node.Retry.MaxAttempts < 1 {
+				nodeErr(fmt.Errorf("retry max_attempts must be at least 1"))
+			}
+			if _, err := parseDuration("min_backoff", node.Retry.MinBackoff); err != nil {
+				nodeErr(err)
+			}
+			if _, err := parseDuration("max_backoff", node.Retry.MaxBackoff); err != nil {
+				nodeErr(err)
+			}
+		}
+	}
+
+	for i, edge := range s.Edges {
+		edgeErr := func(err error) {
+			errs = append(errs, fmt.Errorf("edge %d %v -> %v: %w", i, edge.Source, edge.Target, err))
+		}
+
+		if !names[edge.Source] {
+			edgeErr(fmt.Errorf("source: %w", ErrNodeNotFound))
+		}
+		if !names[edge.Target] {
+			edgeErr(fmt.Errorf("target: %w", ErrNodeNotFound))
+		}
+		if edge.Loop != nil {
+			if edge.Loop.MaxIterations < 1 {
+				edgeErr(fmt.Errorf("%w: max_iterations must be at least 1", ErrInvalidLoop))
+			}
+			if edge.When != nil {
+				edgeErr(fmt.Errorf("%w: loop edges can't have a condition, use until instead", ErrInvalidLoop))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewGraphFromSpec validates the spec and builds its graph with the node types of the registry.
+func NewGraphFromSpec(spec GraphSpec, registry *Registry) (*Graph, error) {
+	if err := spec.Validate(registry); err != nil {
+		return nil, err
+	}
+
+	g := NewGraph()
+	for _, nodeSpec := range spec.Nodes {
+		node, err := registry.New(nodeSpec.Type, nodeSpec.Config)
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %w", nodeSpec.Name, err)
+		}
+		if err := g.AddNode(nodeSpec.Name, node, nodeSpec.options()...); err != nil {
+			return nil, fmt.Errorf("node %q: %w", nodeSpec.Name, err)
+		}
+	}
+
+	// loop edges can only be added once the path from their head to their tail exists
+	edges := slices.Clone(spec.Edges)
+	slices.SortStableFunc(edges, func(a, b EdgeSpec) int {
+		switch {
+		case a.Loop == nil && b.Loop != nil:
+			return -1
+		case a.Loop != nil && b.Loop == nil:
+			return 1
+		}
+		return 0
+	})
+	for _, edgeSpec := range edges {
+		if err := g.AddEdge(edgeSpec.Source, edgeSpec.Target, edgeSpec.options()...); err != nil {
+			return nil, fmt.Errorf("edge %v -> %v: %w", edgeSpec.Source, edgeSpec.Target, err)
+		}
+	}
+	return g, nil
+}
+
+// options returns the options of a validated node spec.
+func (s NodeSpec) options() []func(*NodeOptions) {
+	opts := []func(*NodeOptions){WithType(s.Type, s.Config)}
+	if s.Join != "" {
+		opts = append(opts, WithJoin(s.Join))
+	}
+	if s.InputMode != "" {
+		opts = append(opts, WithInputMode(s.InputMode))
+	}
+	if s.WithArgs {
+		opts = append(opts, WithArgs())
+	}
+	if s.Retry != nil {
+		retry := *s.Retry
+		opts = append(opts, func(opts *NodeOptions) {
+			minBackoff, _ := parseDuration("min_backoff", retry.MinBackoff)
+			maxBackoff, _ := parseDuration("max_backoff", retry.MaxBackoff)
+			opts.maxAttempts = retry.MaxAttempts
+			opts.backoff = ExponentialBackoff(minBackoff, max(minBackoff, maxBackoff))
+			opts.retry = &retry
+		})
+	}
+	if s.Timeout != "" {
+		timeout, _ := parseDuration("timeout", s.Timeout)
+		opts = append(opts, WithTimeout(timeout))
+	}
+	if s.DefaultOutput != nil {
+		opts = append(opts, WithDefaultOutput(s.DefaultOutput))
+	}
+	return opts
+}
+
+// options returns the options of a validated edge spec.
+func (s EdgeSpec) options() []func(*EdgeOptions) {
+	opts := make([]func(*EdgeOptions), 0)
+	for sourceKey, targetKey := range s.Mappings {
+		opts = append(opts, WithMapping(sourceKey, targetKey))
+	}
+	if s.When != nil {
+		opts = append(opts, WithWhen(s.When.Key, s.When.Equals))
+	}
+	if s.Loop != nil {
+		if s.Loop.Until != nil {
+			opts = append(opts, WithLoopUntil(s.Loop.MaxIterations, s.Loop.Until.Key, s.Loop.Until.Equals))
+		} else {
+			opts = append(opts, WithLoop(s.Loop.MaxIterations, nil))
+		}
+	}
+	return opts
+}
+
+// Spec exports the graph to a GraphSpec. It fails with ErrNotExportable if the graph uses options that can't be
+// written in a spec: nodes added without WithType, fallbacks, retryable classifiers, custom backoffs and edge conditions
+// other than WithWhen and WithLoopUntil.
+func (g *Graph) Spec() (GraphSpec, error) {
+	hashes, err := g.store.ListNodes()
+	if err != nil {
+		return GraphSpec{}, err
+	}
+	slices.Sort(hashes)
+
+	spec := GraphSpec{Nodes: make([]NodeSpec, 0, len(hashes))}
+	for _, hash := range hashes {
+		nodeSpec, err := newNodeSpec(hash, g.nodeOptions[hash])
+		if err != nil {
+			return GraphSpec{}, err
+		}
+		spec.Nodes = append(spec.Nodes, nodeSpec)
+	}
+
+	edges, err := g.store.ListEdges()
+	if err != nil {
+		return GraphSpec{}, err
+	}
+	slices.SortFunc(edges, func(a, b Edge) int {
+		if c := strings.Compare(a.SourceHash, b.SourceHash); c != 0 {
+			return c
+		}
+		return strings.Compare(a.TargetHash, b.TargetHash)
+	})
+
+	spec.Edges = make([]EdgeSpec, 0, len(edges))
+	for _, edge := range edges {
+		edgeSpec, err := newEdgeSpec(edge)
+		if err != nil {
+			return GraphSpec{}, err
+		}
+		spec.Edges = append(spec.Edges, edgeSpec)
+	}
+	return spec, nil
+}
+
+func newNodeSpec(hash string, options NodeOptions) (NodeSpec, error) {
+	notExportable := func(reason string) (NodeSpec, error) {
+		return NodeSpec{}, fmt.Errorf("%w: node %v %s", ErrNotExportable, hash, reason)
+	}
+
+	switch {
+	case options.nodeType == "":
+		return notExportable("was added without a type")
+	case options.fallback != nil:
+		return notExportable("has a fallback")
+	case options.retryable != nil:
+		return notExportable("has a retryable classifier")
+	case options.backoff != nil && options.retry == nil:
+		return notExportable("has a custom backoff")
+	}
+
+	spec := NodeSpec{
+		Name:          hash,
+		Type:          options.nodeType,
+		Config:        options.config,
+		WithArgs:      options.withArgs,
+		DefaultOutput: options.defaultOutput,
+	}
+	if options.join != JoinAll {
+		spec.Join = options.join
+	}
+	if options.inputMode != InputMerged {
+		spec.InputMode = options.inputMode
+	}
+	if options.retry != nil {
+		retry := *options.retry
+		spec.Retry = &retry
+	} else if options.maxAttempts > 1 {
+		spec.Retry = &RetrySpec{MaxAttempts: options.maxAttempts}
+	}
+	if options.timeout > 0 {
+		spec.Timeout = options.timeout.String()
+	}
+	return spec, nil
+}
+
+func newEdgeSpec(edge Edge) (EdgeSpec, error) {
+	notExportable := func(reason string) (EdgeSpec, error) {
+		return EdgeSpec{}, fmt.Errorf("%w: edge %v -> %v %s", ErrNotExportable, edge.SourceHash, edge.TargetHash, reason)
+	}
+
+	spec := EdgeSpec{
+		Source:   edge.SourceHash,
+		Target:   edge.TargetHash,
+		Mappings: maps.Clone(edge.Mappings),
+		When:     edge.When,
+	}
+	if edge.Condition != nil && edge.When == nil {
+		return notExportable("has a condition that isn't a when")
+	}
+	if edge.Loop != nil {
+		if edge.Loop.Until != nil && edge.Loop.UntilWhen == nil {
+			return notExportable("has an until condition that isn't a when")
+		}
+		spec.Loop = &LoopSpec{MaxIterations: edge.Loop.MaxIterations, Until: edge.Loop.UntilWhen}
+	}
+	return spec, nil
+}
+
+// edge returns the edge of a validated edge spec.
+func (s EdgeSpec) edge() Edge {
+	options := EdgeOptions{}
+	for _, opt := range s.options() {
+		opt(&options)
+	}
+	return newEdge(s.Source, s.Target, options)
+}
+
+// DecodeConfig decodes the config of a node spec into T, following its json tags. It is meant to be used
+// by node constructors.
+func DecodeConfig[T any](config map[string]any) (T, error) {
+	var t T
+	data, err := json.Marshal(config)
+	if err != nil {
+		return t, fmt.Errorf("could not decode config: %w", err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("could not decode config: %w", err)
+	}
+	return t, nil
+}
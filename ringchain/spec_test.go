@@ -0,0 +1,181 @@
+package ringchain
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gopkg.in/yaml.v3"
+)
+
+const testGraphSpec = `
+nodes:
+  - name: answer
+    type: test
+    with_args: true
+  - name: classify
+    type: classifier
+    config:
+      class: question
+    retry: {max_attempts: 3, min_backoff: 1ms, max_backoff: 10ms}
+    timeout: 1s
+  - name: complaint
+    type: test
+  - name: critique
+    type: counter
+    config: {name: critique}
+  - name: draft
+    type: counter
+    config: {name: draft}
+edges:
+  - source: classify
+    target: complaint
+    when: {key: class, equals: complaint}
+  - source: classify
+    target: draft
+    when: {key: class, equals: question}
+  - source: critique
+    target: answer
+    mappings: {critique: critiques}
+  - source: critique
+    target: draft
+    loop:
+      max_iterations: 5
+      until: {key: critique, equals: 3}
+  - source: draft
+    target: critique
+`
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register("test", func(config map[string]any) (Node, error) {
+		return TestNode{name: "test"}, nil
+	}))
+	require.NoError(t, registry.Register("counter", func(config map[string]any) (Node, error) {
+		c, err := DecodeConfig[struct {
+			Name string `json:"name"`
+		}](config)
+		if err != nil {
+			return nil, err
+		}
+		return CounterNode{name: c.Name}, nil
+	}))
+	require.NoError(t, registry.Register("classifier", func(config map[string]any) (Node, error) {
+		c, err := DecodeConfig[struct {
+			Class string `json:"class"`
+		}](config)
+		if err != nil {
+			return nil, err
+		}
+		return ClassifierNode{name: "classify", class: c.Class}, nil
+	}))
+	return registry
+}
+
+func TestNewGraphFromSpec(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+
+	spec, err := ParseGraphSpec([]byte(testGraphSpec))
+	require.NoError(t, err)
+	g, err := NewGraphFromSpec(spec, registry)
+	require.NoError(t, err)
+
+	res, err := g.Execute(ctx, logger, map[string]any{"question": "why"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"critiques": 3, "question": "why", "n_test": true}, res["answer"])
+	assert.NotContains(t, res, "complaint")
+
+	t.Run("Export", func(t *testing.T) {
+		exported, err := g.Spec()
+		require.NoError(t, err)
+		assert.Equal(t, spec, exported)
+
+		data, err := yaml.Marshal(exported)
+		require.NoError(t, err)
+		reparsed, err := ParseGraphSpec(data)
+		require.NoError(t, err)
+		assert.Equal(t, spec, reparsed)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		spec, err := ParseGraphSpec([]byte(`{"nodes": [{"name": "1", "type": "test"}, {"name": "2", "type": "test"}], "edges": [{"source": "1", "target": "2"}]}`))
+		require.NoError(t, err)
+		_, err = NewGraphFromSpec(spec, registry)
+		assert.NoError(t, err)
+	})
+
+	t.Run("LoadGraph", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "graph.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(testGraphSpec), 0o644))
+		_, err := LoadGraph(path, registry)
+		assert.NoError(t, err)
+	})
+}
+
+func TestGraphSpec_Validate(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, err := ParseGraphSpec([]byte("nodes:\n  - name: 1\n    typ: test\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("AllErrors", func(t *testing.T) {
+		spec := GraphSpec{
+			Nodes: []NodeSpec{
+				{Name: "1", Type: "test"},
+				{Name: "1", Type: "missing", Join: "some", Timeout: "soon"},
+			},
+			Edges: []EdgeSpec{{Source: "1", Target: "2"}},
+		}
+		err := spec.Validate(registry)
+		assert.ErrorIs(t, err, ErrNodeAlreadyExists)
+		assert.ErrorIs(t, err, ErrUnknownNodeType)
+		assert.ErrorIs(t, err, ErrUnknownJoin)
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+		assert.ErrorContains(t, err, "invalid timeout")
+
+		_, err = NewGraphFromSpec(spec, registry)
+		assert.ErrorIs(t, err, ErrUnknownNodeType)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		spec := GraphSpec{
+			Nodes: []NodeSpec{{Name: "1", Type: "test"}, {Name: "2", Type: "test"}},
+			Edges: []EdgeSpec{{Source: "1", Target: "2"}, {Source: "2", Target: "1"}},
+		}
+		_, err := NewGraphFromSpec(spec, registry)
+		assert.ErrorIs(t, err, ErrEdgeCreatesCycle)
+	})
+}
+
+func TestGraph_SpecNotExportable(t *testing.T) {
+	t.Run("Untyped", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("1", TestNode{name: "1"}))
+		_, err := g.Spec()
+		assert.ErrorIs(t, err, ErrNotExportable)
+	})
+
+	t.Run("Condition", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("1", TestNode{name: "1"}, WithType("test", nil)))
+		require.NoError(t, g.AddNode("2", TestNode{name: "2"}, WithType("test", nil)))
+		require.NoError(t, g.AddEdge("1", "2", WithCondition(WhenEquals("key", true))))
+		_, err := g.Spec()
+		assert.ErrorIs(t, err, ErrNotExportable)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		g := NewGraph()
+		require.NoError(t, g.AddNode("1", TestNode{name: "1"}, WithType("test", nil), WithFallback(TestNode{name: "fallback"})))
+		_, err := g.Spec()
+		assert.ErrorIs(t, err, ErrNotExportable)
+	})
+}
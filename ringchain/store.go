@@ -58,10 +58,26 @@ type Store interface {
 	// ListEdges should return all edges in the graph in a slice.
 	ListEdges() ([]Edge, error)
 
+	// CreatesCycle should return whether an edge from source to target would create a cycle,
+	// that is whether target is source or one of its ancestors. Loop edges should be ignored.
 	CreatesCycle(source, target string) (bool, error)
 }
 
-type memoryStore struct {
+// SpecStore is implemented by durable stores that persist the spec nodes are built from rather than their value,
+// like FileStore. Graph adds nodes to them with AddNodeSpec, so only nodes with a type can be added, see WithType.
+type SpecStore interface {
+	Store
+
+	// AddNodeSpec should add the node built from the spec under the name of the spec, like AddNode.
+	AddNodeSpec(spec NodeSpec, value Node) error
+
+	// NodeSpec should return the spec of the node with the given hash value. If the node doesn't exist,
+	// ErrNodeNotFound should be returned.
+	NodeSpec(hash string) (NodeSpec, error)
+}
+
+// MemoryStore keeps the graph in memory, it is the default store of graphs.
+type MemoryStore struct {
 	lock     sync.RWMutex
 	vertices map[string]Node
 
@@ -71,15 +87,17 @@ type memoryStore struct {
 	inEdges  map[string]map[string]Edge // target -> source
 }
 
-func newMemoryStore() Store {
-	return &memoryStore{
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
 		vertices: make(map[string]Node),
 		outEdges: make(map[string]map[string]Edge),
 		inEdges:  make(map[string]map[string]Edge),
 	}
 }
 
-func (s *memoryStore) AddNode(k string, t Node) error {
+func (s *MemoryStore) AddNode(k string, t Node) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -92,7 +110,7 @@ func (s *memoryStore) AddNode(k string, t Node) error {
 	return nil
 }
 
-func (s *memoryStore) ListNodes() ([]string, error) {
+func (s *MemoryStore) ListNodes() ([]string, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -104,14 +122,14 @@ func (s *memoryStore) ListNodes() ([]string, error) {
 	return hashes, nil
 }
 
-func (s *memoryStore) NodeCount() (int, error) {
+func (s *MemoryStore) NodeCount() (int, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
 	return len(s.vertices), nil
 }
 
-func (s *memoryStore) Node(k string) (Node, error) {
+func (s *MemoryStore) Node(k string) (Node, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -123,9 +141,9 @@ func (s *memoryStore) Node(k string) (Node, error) {
 	return v, nil
 }
 
-func (s *memoryStore) RemoveNode(k string) error {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+func (s *MemoryStore) RemoveNode(k string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
 	if _, ok := s.vertices[k]; !ok {
 		return ErrNodeNotFound
@@ -149,10 +167,20 @@ func (s *memoryStore) RemoveNode(k string) error {
 	return nil
 }
 
-func (s *memoryStore) AddEdge(sourceHash, targetHash string, edge Edge) error {
+func (s *MemoryStore) AddEdge(sourceHash, targetHash string, edge Edge) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if _, ok := s.vertices[sourceHash]; !ok {
+		return fmt.Errorf("source: %w", ErrNodeNotFound)
+	}
+	if _, ok := s.vertices[targetHash]; !ok {
+		return fmt.Errorf("target: %w", ErrNodeNotFound)
+	}
+	if _, ok := s.outEdges[sourceHash][targetHash]; ok {
+		return ErrEdgeAlreadyExists
+	}
+
 	if _, ok := s.outEdges[sourceHash]; !ok {
 		s.outEdges[sourceHash] = make(map[string]Edge)
 	}
@@ -168,21 +196,21 @@ func (s *memoryStore) AddEdge(sourceHash, targetHash string, edge Edge) error {
 	return nil
 }
 
-func (s *memoryStore) UpdateEdge(sourceHash string, targetHash string, edge Edge) error {
-	if _, err := s.Edge(sourceHash, targetHash); err != nil {
-		return err
-	}
-
+func (s *MemoryStore) UpdateEdge(sourceHash string, targetHash string, edge Edge) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if _, ok := s.outEdges[sourceHash][targetHash]; !ok {
+		return ErrEdgeNotFound
+	}
+
 	s.outEdges[sourceHash][targetHash] = edge
 	s.inEdges[targetHash][sourceHash] = edge
 
 	return nil
 }
 
-func (s *memoryStore) RemoveEdge(sourceHash, targetHash string) error {
+func (s *MemoryStore) RemoveEdge(sourceHash, targetHash string) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -191,7 +219,7 @@ func (s *memoryStore) RemoveEdge(sourceHash, targetHash string) error {
 	return nil
 }
 
-func (s *memoryStore) Edge(sourceHash, targetHash string) (Edge, error) {
+func (s *MemoryStore) Edge(sourceHash, targetHash string) (Edge, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -208,7 +236,7 @@ func (s *memoryStore) Edge(sourceHash, targetHash string) (Edge, error) {
 	return edge, nil
 }
 
-func (s *memoryStore) ListEdges() ([]Edge, error) {
+func (s *MemoryStore) ListEdges() ([]Edge, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -221,7 +249,7 @@ func (s *memoryStore) ListEdges() ([]Edge, error) {
 	return res, nil
 }
 
-func (s *memoryStore) CreatesCycle(source, target string) (bool, error) {
+func (s *MemoryStore) CreatesCycle(source, target string) (bool, error) {
 	if _, err := s.Node(source); err != nil {
 		return false, fmt.Errorf("could not get vertex with hash %v: %w", source, err)
 	}
@@ -251,7 +279,11 @@ func (s *memoryStore) CreatesCycle(source, target string) (bool, error) {
 
 			visited[currentHash] = struct{}{}
 
-			for adjacency := range s.inEdges[currentHash] {
+			for adjacency, edge := range s.inEdges[currentHash] {
+				// loop edges are the only cycles allowed
+				if edge.Loop != nil {
+					continue
+				}
 				stack.push(adjacency)
 			}
 		}
@@ -0,0 +1,27 @@
+package ringchain_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dskart/gollum/ringchain"
+	"github.com/dskart/gollum/ringchain/storetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) ringchain.Store {
+		return ringchain.NewMemoryStore()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) ringchain.Store {
+		store, err := ringchain.OpenFileStore(filepath.Join(t.TempDir(), "graph.jsonl"), ringchain.NewRegistry())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, store.Close())
+		})
+		return store
+	})
+}
@@ -0,0 +1,248 @@
+// Package storetest provides conformance tests for ringchain.Store implementations.
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.TestStore(t, func(t *testing.T) ringchain.Store {
+//			return NewMyStore()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dskart/gollum/ringchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Node is the node added to the stores under test.
+type Node struct {
+	name string
+}
+
+func NewNode(name string) Node {
+	return Node{name: name}
+}
+
+func (n Node) Name() string {
+	return n.name
+}
+
+func (n Node) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	return maps.Clone(args), nil
+}
+
+// NodeType is the type of the nodes added to spec stores, which can only persist typed nodes.
+const NodeType = "storetest"
+
+// addNode adds a node to the store, with a NodeSpec of NodeType if it is a spec store.
+func addNode(store ringchain.Store, hash string) error {
+	if specStore, ok := store.(ringchain.SpecStore); ok {
+		return specStore.AddNodeSpec(ringchain.NodeSpec{Name: hash, Type: NodeType}, NewNode(hash))
+	}
+	return store.AddNode(hash, NewNode(hash))
+}
+
+// TestStore checks that the stores returned by newStore follow the contract documented on ringchain.Store.
+// newStore is called for every subtest and must return an empty store.
+func TestStore(t *testing.T, newStore func(t *testing.T) ringchain.Store) {
+	t.Run("AddNode", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+		require.NoError(t, addNode(store, "2"))
+
+		node, err := store.Node("1")
+		require.NoError(t, err)
+		assert.Equal(t, "1", node.Name())
+
+		hashes, err := store.ListNodes()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"1", "2"}, hashes)
+
+		count, err := store.NodeCount()
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		if err := addNode(store, "1"); err != nil {
+			assert.ErrorIs(t, err, ringchain.ErrNodeAlreadyExists)
+		}
+	})
+
+	t.Run("NodeNotFound", func(t *testing.T) {
+		store := newStore(t)
+		_, err := store.Node("missing")
+		assert.ErrorIs(t, err, ringchain.ErrNodeNotFound)
+		assert.ErrorIs(t, store.RemoveNode("missing"), ringchain.ErrNodeNotFound)
+	})
+
+	t.Run("RemoveNode", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+		require.NoError(t, addNode(store, "2"))
+		require.NoError(t, store.AddEdge("1", "2", ringchain.Edge{SourceHash: "1", TargetHash: "2"}))
+
+		assert.ErrorIs(t, store.RemoveNode("1"), ringchain.ErrNodeHasEdges)
+		assert.ErrorIs(t, store.RemoveNode("2"), ringchain.ErrNodeHasEdges)
+
+		require.NoError(t, store.RemoveEdge("1", "2"))
+		require.NoError(t, store.RemoveNode("1"))
+		_, err := store.Node("1")
+		assert.ErrorIs(t, err, ringchain.ErrNodeNotFound)
+
+		count, err := store.NodeCount()
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("AddEdge", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+		require.NoError(t, addNode(store, "2"))
+
+		edge := ringchain.Edge{SourceHash: "1", TargetHash: "2", Mappings: map[string]string{"a": "b"}}
+		require.NoError(t, store.AddEdge("1", "2", edge))
+
+		got, err := store.Edge("1", "2")
+		require.NoError(t, err)
+		assert.Equal(t, edge.SourceHash, got.SourceHash)
+		assert.Equal(t, edge.TargetHash, got.TargetHash)
+		assert.Equal(t, edge.Mappings, got.Mappings)
+
+		_, err = store.Edge("2", "1")
+		assert.ErrorIs(t, err, ringchain.ErrEdgeNotFound)
+
+		edges, err := store.ListEdges()
+		require.NoError(t, err)
+		assert.Len(t, edges, 1)
+
+		assert.ErrorIs(t, store.AddEdge("1", "2", edge), ringchain.ErrEdgeAlreadyExists)
+		assert.ErrorIs(t, store.AddEdge("1", "missing", ringchain.Edge{SourceHash: "1", TargetHash: "missing"}), ringchain.ErrNodeNotFound)
+		assert.ErrorIs(t, store.AddEdge("missing", "1", ringchain.Edge{SourceHash: "missing", TargetHash: "1"}), ringchain.ErrNodeNotFound)
+	})
+
+	t.Run("UpdateEdge", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+		require.NoError(t, addNode(store, "2"))
+		require.NoError(t, store.AddEdge("1", "2", ringchain.Edge{SourceHash: "1", TargetHash: "2"}))
+
+		updated := ringchain.Edge{SourceHash: "1", TargetHash: "2", Mappings: map[string]string{"a": "b"}}
+		require.NoError(t, store.UpdateEdge("1", "2", updated))
+		got, err := store.Edge("1", "2")
+		require.NoError(t, err)
+		assert.Equal(t, updated.Mappings, got.Mappings)
+
+		assert.ErrorIs(t, store.UpdateEdge("2", "1", ringchain.Edge{SourceHash: "2", TargetHash: "1"}), ringchain.ErrEdgeNotFound)
+	})
+
+	t.Run("RemoveEdge", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+		require.NoError(t, addNode(store, "2"))
+		require.NoError(t, store.AddEdge("1", "2", ringchain.Edge{SourceHash: "1", TargetHash: "2"}))
+
+		require.NoError(t, store.RemoveEdge("1", "2"))
+		_, err := store.Edge("1", "2")
+		assert.ErrorIs(t, err, ringchain.ErrEdgeNotFound)
+
+		edges, err := store.ListEdges()
+		require.NoError(t, err)
+		assert.Empty(t, edges)
+
+		if err := store.RemoveEdge("1", "2"); err != nil {
+			assert.ErrorIs(t, err, ringchain.ErrEdgeNotFound)
+		}
+	})
+
+	t.Run("CreatesCycle", func(t *testing.T) {
+		// 1 -> 2 -> 3, and 3 loops back to 2
+		store := newStore(t)
+		for _, hash := range []string{"1", "2", "3"} {
+			require.NoError(t, addNode(store, hash))
+		}
+		require.NoError(t, store.AddEdge("1", "2", ringchain.Edge{SourceHash: "1", TargetHash: "2"}))
+		require.NoError(t, store.AddEdge("2", "3", ringchain.Edge{SourceHash: "2", TargetHash: "3"}))
+		loop := ringchain.Edge{SourceHash: "3", TargetHash: "2", Loop: &ringchain.Loop{MaxIterations: 2}}
+		require.NoError(t, store.AddEdge("3", "2", loop))
+
+		for _, tc := range []struct {
+			source, target string
+			cycle          bool
+		}{
+			{"3", "1", true},
+			{"2", "1", true},
+			{"1", "1", true},
+			{"1", "3", false},
+			// loop edges are ignored
+			{"2", "3", false},
+		} {
+			cycle, err := store.CreatesCycle(tc.source, tc.target)
+			require.NoError(t, err)
+			assert.Equal(t, tc.cycle, cycle, "%v -> %v", tc.source, tc.target)
+		}
+
+		_, err := store.CreatesCycle("1", "missing")
+		assert.ErrorIs(t, err, ringchain.ErrNodeNotFound)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "root"))
+
+		var wg sync.WaitGroup
+		for i := range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				hash := string(rune('a' + i))
+				assert.NoError(t, addNode(store, hash))
+				assert.NoError(t, store.AddEdge("root", hash, ringchain.Edge{SourceHash: "root", TargetHash: hash}))
+				_, _ = store.ListNodes()
+				_, _ = store.ListEdges()
+			}()
+		}
+		wg.Wait()
+
+		hashes, err := store.ListNodes()
+		require.NoError(t, err)
+		assert.Len(t, hashes, 21)
+		edges, err := store.ListEdges()
+		require.NoError(t, err)
+		assert.Len(t, edges, 20)
+		assert.True(t, slices.ContainsFunc(edges, func(edge ringchain.Edge) bool { return edge.TargetHash == "a" }))
+	})
+
+	t.Run("Unreadable", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, addNode(store, "1"))
+
+		// a graph on a store it can't read is never changed nor executed
+		g := ringchain.NewGraph(ringchain.WithStore(unreadableStore{store}))
+		err := g.AddNode("2", NewNode("2"), ringchain.WithType(NodeType, nil))
+		assert.ErrorIs(t, err, errUnreadable)
+		assert.ErrorIs(t, g.AddEdge("1", "1"), errUnreadable)
+		_, err = g.Execute(context.Background(), zap.NewNop(), map[string]any{})
+		assert.ErrorIs(t, err, errUnreadable)
+
+		hashes, err := store.ListNodes()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1"}, hashes)
+	})
+}
+
+var errUnreadable = errors.New("unreadable store")
+
+// unreadableStore fails to list the nodes of the store it wraps.
+type unreadableStore struct {
+	ringchain.Store
+}
+
+func (s unreadableStore) ListNodes() ([]string, error) {
+	return nil, errUnreadable
+}
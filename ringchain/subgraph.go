@@ -0,0 +1,319 @@
+package ringchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// PathSeparator separates the hashes of nested nodes in the events and errors of subgraphs.
+const PathSeparator = "/"
+
+// SubgraphError is returned when a node of a subgraph fails, Path is the path of the node from the outermost graph.
+type SubgraphError struct {
+	Path string
+	Err  error
+}
+
+func (e *SubgraphError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *SubgraphError) Unwrap() error {
+	return e.Err
+}
+
+type SubgraphOptions struct {
+	inputs  []subgraphInput
+	outputs []subgraphOutput
+}
+
+type subgraphInput struct {
+	arg      string
+	nodeHash string
+	key      string
+}
+
+type subgraphOutput struct {
+	nodeHash string
+	key      string
+	output   string
+}
+
+// WithSubgraphInput passes the arg of the subgraph to the root node nodeHash, as key. Once an input is declared,
+// root nodes only receive the args mapped to them, otherwise they all receive every arg.
+func WithSubgraphInput(arg, nodeHash, key string) func(*SubgraphOptions) {
+	return func(opts *SubgraphOptions) {
+		opts.inputs = append(opts.inputs, subgraphInput{arg: arg, nodeHash: nodeHash, key: key})
+	}
+}
+
+// WithSubgraphOutput returns the key of the result of the sink node nodeHash as output. Once an output is declared,
+// the subgraph only returns its declared outputs, otherwise it returns the results of its sink nodes merged in
+// the order of their hashes.
+func WithSubgraphOutput(nodeHash, key, output string) func(*SubgraphOptions) {
+	return func(opts *SubgraphOptions) {
+		opts.outputs = append(opts.outputs, subgraphOutput{nodeHash: nodeHash, key: key, output: output})
+	}
+}
+
+// Subgraph is a Node that executes a graph. The events of its nodes are emitted by the parent execution and
+// the errors of its nodes are returned as a SubgraphError, both with the path of the node as hash: "subgraph/node".
+type Subgraph struct {
+	name    string
+	graph   *Graph
+	options SubgraphOptions
+}
+
+var (
+	_ Node           = (*Subgraph)(nil)
+	_ OutputDeclarer = (*Subgraph)(nil)
+)
+
+// NewSubgraph creates a subgraph node executing graph. Inputs must be declared on root nodes and outputs on sink
+// nodes, ignoring loop edges, or ErrInvalidSubgraph is returned.
+func NewSubgraph(name string, graph *Graph, opts ...func(*SubgraphOptions)) (*Subgraph, error) {
+	options := SubgraphOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	predecessors, err := graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+	successors, err := graph.SuccessorMap()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, input := range options.inputs {
+		edges, ok := predecessors[input.nodeHash]
+		if !ok {
+			return nil, fmt.Errorf("input %q: node %v: %w", input.arg, input.nodeHash, ErrNodeNotFound)
+		}
+		if hasForwardEdge(edges) {
+			return nil, fmt.Errorf("%w: input %q: %v is not a root node", ErrInvalidSubgraph, input.arg, input.nodeHash)
+		}
+	}
+
+	seen := make(map[string]bool, len(options.outputs))
+	for _, output := range options.outputs {
+		edges, ok := successors[output.nodeHash]
+		if !ok {
+			return nil, fmt.Errorf("output %q: node %v: %w", output.output, output.nodeHash, ErrNodeNotFound)
+		}
+		if hasForwardEdge(edges) {
+			return nil, fmt.Errorf("%w: output %q: %v is not a sink node", ErrInvalidSubgraph, output.output, output.nodeHash)
+		}
+		if seen[output.output] {
+			return nil, fmt.Errorf("%w: output %q is declared twice", ErrInvalidSubgraph, output.output)
+		}
+		seen[output.output] = true
+
+		node, _ := graph.Node(output.nodeHash)
+		if declarer, ok := node.(OutputDeclarer); ok && declarer.Outputs() != nil && !slices.Contains(declarer.Outputs(), output.key) {
+			return nil, fmt.Errorf("%w: %v doesn't declare %q", ErrUnknownOutput, output.nodeHash, output.key)
+		}
+	}
+
+	return &Subgraph{
+		name:    name,
+		graph:   graph,
+		options: options,
+	}, nil
+}
+
+func hasForwardEdge(edges map[string]Edge) bool {
+	for _, edge := range edges {
+		if edge.Loop == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subgraph) Name() string {
+	return s.name
+}
+
+// Graph returns the graph the subgraph executes.
+func (s *Subgraph) Graph() *Graph {
+	return s.graph
+}
+
+// Outputs returns the declared outputs of the subgraph, or nil if there are none.
+func (s *Subgraph) Outputs() []string {
+	if len(s.options.outputs) == 0 {
+		return nil
+	}
+	outputs := make([]string, 0, len(s.options.outputs))
+	for _, output := range s.options.outputs {
+		outputs = append(outputs, output.output)
+	}
+	return outputs
+}
+
+// EntryNodes returns the hashes of the nodes receiving the args of the subgraph, sorted.
+func (s *Subgraph) EntryNodes() ([]string, error) {
+	if len(s.options.inputs) > 0 {
+		hashes := make([]string, 0, len(s.options.inputs))
+		for _, input := range s.options.inputs {
+			hashes = append(hashes, input.nodeHash)
+		}
+		slices.Sort(hashes)
+		return slices.Compact(hashes), nil
+	}
+
+	predecessors, err := s.graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+	return endNodes(predecessors), nil
+}
+
+// ExitNodes returns the hashes of the nodes the outputs of the subgraph come from, sorted.
+func (s *Subgraph) ExitNodes() ([]string, error) {
+	if len(s.options.outputs) > 0 {
+		hashes := make([]string, 0, len(s.options.outputs))
+		for _, output := range s.options.outputs {
+			hashes = append(hashes, output.nodeHash)
+		}
+		slices.Sort(hashes)
+		return slices.Compact(hashes), nil
+	}
+
+	successors, err := s.graph.SuccessorMap()
+	if err != nil {
+		return nil, err
+	}
+	return endNodes(successors), nil
+}
+
+// endNodes returns the sorted nodes without forward edges in the adjacency map.
+func endNodes(adjacencyMap map[string]map[string]Edge) []string {
+	hashes := make([]string, 0)
+	for hash, edges := range adjacencyMap {
+		if !hasForwardEdge(edges) {
+			hashes = append(hashes, hash)
+		}
+	}
+	slices.Sort(hashes)
+	return hashes
+}
+
+func (s *Subgraph) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	scope := scopeFromContext(ctx)
+	path := scope.path
+	if len(path) == 0 {
+		path = []string{s.name}
+	}
+	prefix := path[len(path)-1] + PathSeparator
+
+	options := newGraphExecuteOptions(nil)
+	if scope.emitter != nil && scope.emitter.handler != nil {
+		options.EventHandler = func(event Event) {
+			// the node events of the parent cover the execution of the subgraph
+			if event.Type == ExecutionStartedEventType || event.Type == ExecutionFinishedEventType {
+				return
+			}
+			event.NodeHash = prefix + event.NodeHash
+			scope.emitter.emit(event)
+		}
+	}
+	if len(s.options.inputs) > 0 {
+		options.rootArgs = make(map[string]map[string]any)
+		for _, input := range s.options.inputs {
+			if options.rootArgs[input.nodeHash] == nil {
+				options.rootArgs[input.nodeHash] = make(map[string]any)
+			}
+			if v, ok := args[input.arg]; ok {
+				options.rootArgs[input.nodeHash][input.key] = v
+			}
+		}
+	}
+
+	results, err := s.graph.execute(ctx, logger.With(zap.String("subgraph", strings.Join(path, PathSeparator))), args, options, nil)
+	if err != nil {
+		var nodeErr *nodeError
+		if !errors.As(err, &nodeErr) {
+			return nil, err
+		}
+		// nested subgraphs already return the full path of their failed node
+		var subgraphErr *SubgraphError
+		if errors.As(nodeErr.err, &subgraphErr) {
+			return nil, subgraphErr
+		}
+		return nil, &SubgraphError{Path: strings.Join(append(slices.Clone(path), nodeErr.nodeHash), PathSeparator), Err: nodeErr.err}
+	}
+
+	output := make(map[string]any)
+	if len(s.options.outputs) > 0 {
+		for _, o := range s.options.outputs {
+			if v, ok := results[o.nodeHash][o.key]; ok {
+				output[o.output] = v
+			}
+		}
+		return output, nil
+	}
+
+	sinks, err := s.ExitNodes()
+	if err != nil {
+		return nil, err
+	}
+	for _, sink := range sinks {
+		maps.Copy(output, results[sink])
+	}
+	return output, nil
+}
+
+// nodeScope is the path of the running node and the emitter of its execution, subgraphs use them to nest
+// their events and errors.
+type nodeScope struct {
+	path    []string
+	emitter *emitter
+}
+
+type nodeScopeKey struct{}
+
+func withNodeScope(ctx context.Context, nodeHash string, emitter *emitter) context.Context {
+	parent := scopeFromContext(ctx)
+	return context.WithValue(ctx, nodeScopeKey{}, nodeScope{
+		path:    append(slices.Clone(parent.path), nodeHash),
+		emitter: emitter,
+	})
+}
+
+func scopeFromContext(ctx context.Context) nodeScope {
+	scope, _ := ctx.Value(nodeScopeKey{}).(nodeScope)
+	return scope
+}
+
+// nodeError is the error of the node that failed an execution. Execute and Resume return the error of the node
+// as is, subgraphs use the hash to build the path of the node.
+type nodeError struct {
+	nodeHash string
+	err      error
+}
+
+func (e *nodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nodeError) Unwrap() error {
+	return e.err
+}
+
+// unwrapNodeError returns the error of the failed node if err is a nodeError.
+func unwrapNodeError(err error) error {
+	var nodeErr *nodeError
+	if errors.As(err, &nodeErr) {
+		return nodeErr.err
+	}
+	return err
+}
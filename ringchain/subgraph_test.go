@@ -0,0 +1,136 @@
+package ringchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// newSummaryGraph returns the graph question -> summary, with an error node after question if broken.
+func newSummaryGraph(t *testing.T, broken bool) *Graph {
+	g := NewGraph()
+	require.NoError(t, g.AddNode("question", EchoNode{name: "question"}))
+	require.NoError(t, g.AddNode("summary", SummaryNode{name: "summary"}, WithArgs()))
+	require.NoError(t, g.AddEdge("question", "summary"))
+	if broken {
+		require.NoError(t, g.AddNode("broken", ErrorNode{name: "broken"}))
+		require.NoError(t, g.AddEdge("question", "broken"))
+	}
+	return g
+}
+
+func TestSubgraph(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("Mappings", func(t *testing.T) {
+		sub, err := NewSubgraph("sub", newSummaryGraph(t, false),
+			WithSubgraphInput("text", "question", "question"),
+			WithSubgraphOutput("summary", "summary", "sub_summary"),
+		)
+		require.NoError(t, err)
+
+		g := NewGraph()
+		require.NoError(t, g.AddNode("sub", sub))
+		require.NoError(t, g.AddNode("other", SummaryNode{name: "other"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}))
+		require.NoError(t, g.AddEdge("sub", "target"))
+		require.NoError(t, g.AddEdge("other", "target"))
+
+		res, err := g.Execute(ctx, logger, map[string]any{"text": "why", "ignored": true})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"sub_summary": "summary of summary"}, res["sub"])
+		assert.Equal(t, map[string]any{"sub_summary": "summary of summary", "summary": "summary of other"}, res["target"])
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		sub, err := NewSubgraph("sub", newSummaryGraph(t, false))
+		require.NoError(t, err)
+		assert.Nil(t, sub.Outputs())
+
+		res, err := sub.Run(ctx, logger, map[string]any{"text": "why"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"summary": "summary of summary"}, res)
+	})
+
+	t.Run("OutputCollision", func(t *testing.T) {
+		sub, err := NewSubgraph("sub", newSummaryGraph(t, false), WithSubgraphOutput("summary", "summary", "summary"))
+		require.NoError(t, err)
+
+		g := NewGraph()
+		require.NoError(t, g.AddNode("sub", sub))
+		require.NoError(t, g.AddNode("other", SummaryNode{name: "other"}))
+		require.NoError(t, g.AddNode("target", EchoNode{name: "target"}))
+		require.NoError(t, g.AddEdge("sub", "target"))
+		assert.ErrorIs(t, g.AddEdge("other", "target"), ErrInputCollision)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewSubgraph("sub", newSummaryGraph(t, false), WithSubgraphInput("text", "summary", "question"))
+		assert.ErrorIs(t, err, ErrInvalidSubgraph)
+		_, err = NewSubgraph("sub", newSummaryGraph(t, false), WithSubgraphOutput("question", "question", "question"))
+		assert.ErrorIs(t, err, ErrInvalidSubgraph)
+		_, err = NewSubgraph("sub", newSummaryGraph(t, false), WithSubgraphOutput("summary", "answer", "answer"))
+		assert.ErrorIs(t, err, ErrUnknownOutput)
+		_, err = NewSubgraph("sub", newSummaryGraph(t, false), WithSubgraphInput("text", "missing", "question"))
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+	})
+}
+
+func TestSubgraph_Nested(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	// outer runs inner, which runs question -> summary
+	newGraph := func(broken bool) *Graph {
+		inner, err := NewSubgraph("inner", newSummaryGraph(t, broken))
+		require.NoError(t, err)
+		middle := NewGraph()
+		require.NoError(t, middle.AddNode("inner", inner))
+		outer, err := NewSubgraph("outer", middle)
+		require.NoError(t, err)
+
+		g := NewGraph()
+		require.NoError(t, g.AddNode("outer", outer))
+		return g
+	}
+
+	t.Run("Events", func(t *testing.T) {
+		hashes := make(map[string][]EventType)
+		res, err := newGraph(false).Execute(ctx, logger, map[string]any{"text": "why"}, WithExecutionID("execution"), WithEventHandler(func(event Event) {
+			assert.Equal(t, "execution", event.ExecutionID)
+			hashes[event.NodeHash] = append(hashes[event.NodeHash], event.Type)
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"summary": "summary of summary"}, res["outer"])
+
+		nodeEvents := []EventType{NodeQueuedEventType, NodeStartedEventType, NodeSucceededEventType}
+		assert.Equal(t, map[string][]EventType{
+			"":                     {ExecutionStartedEventType, ExecutionFinishedEventType},
+			"outer":                nodeEvents,
+			"outer/inner":          nodeEvents,
+			"outer/inner/question": nodeEvents,
+			"outer/inner/summary":  nodeEvents,
+		}, hashes)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		failed := make([]string, 0)
+		_, err := newGraph(true).Execute(ctx, logger, map[string]any{}, WithEventHandler(func(event Event) {
+			if event.Type == NodeFailedEventType {
+				failed = append(failed, event.NodeHash)
+			}
+		}))
+		require.Error(t, err)
+		assert.Equal(t, "outer/inner/broken: node is broken.", err.Error())
+
+		var subgraphErr *SubgraphError
+		require.True(t, errors.As(err, &subgraphErr))
+		assert.Equal(t, "outer/inner/broken", subgraphErr.Path)
+		assert.Equal(t, []string{"outer/inner/broken", "outer/inner", "outer"}, failed)
+	})
+}
@@ -2,120 +2,115 @@ package ringchain
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 type nodeJob struct {
-	Args   map[string]any
-	Node   Node
-	Logger *zap.Logger
-	Result chan<- map[string]any
+	NodeHash string
+	Args     map[string]any
+	Node     Node
+	Options  NodeOptions
+	Logger   *zap.Logger
 }
 
-type runningNode struct {
+// nodeCompletion is sent by a worker once a node has run, successfully or not.
+type nodeCompletion struct {
 	NodeHash string
-	Result   <-chan map[string]any
+	Result   map[string]any
+	Attempts int
+	Latency  time.Duration
+	// Routes are the successors activated by a Router, they are nil for other nodes
+	Routes []string
+	Err    error
+	// replayed is set when the result comes from the checkpoint of a resumed execution
+	replayed bool
 }
 
+// workerPool runs node jobs on a fixed number of workers and reports every result on a single completion channel.
+// Both channels are buffered with the number of nodes so neither the scheduler nor the workers ever block on each other.
 type workerPool struct {
-	numWorkers   int
-	queueSize    int
-	jobQueue     chan nodeJob
-	doneChan     chan bool
-	runningNodes []runningNode
-	errChan      chan error
+	numWorkers  int
+	jobQueue    chan nodeJob
+	completions chan nodeCompletion
+	emitter     *emitter
 
-	eg *errgroup.Group
+	wg sync.WaitGroup
 }
 
-func newWorkerPool(numWorkers int, queueSize int) *workerPool {
+func newWorkerPool(numWorkers int, queueSize int, emitter *emitter) *workerPool {
+	// there is no point in starting more workers than there are nodes to run
+	numWorkers = max(1, min(numWorkers, queueSize))
 	return &workerPool{
-		numWorkers:   numWorkers,
-		queueSize:    queueSize,
-		jobQueue:     make(chan nodeJob, queueSize),
-		doneChan:     make(chan bool, numWorkers),
-		runningNodes: make([]runningNode, 0, queueSize),
-		errChan:      make(chan error),
+		numWorkers:  numWorkers,
+		jobQueue:    make(chan nodeJob, queueSize),
+		completions: make(chan nodeCompletion, queueSize),
+		emitter:     emitter,
 	}
 }
 
-func (p *workerPool) Run(ctx context.Context) error {
-	eg, ctx := errgroup.WithContext(ctx)
+func (p *workerPool) Run(ctx context.Context) {
 	for w := 1; w <= p.numWorkers; w++ {
-		eg.Go(func() error {
-			return worker(ctx, p.doneChan, p.jobQueue, p.errChan)
-		})
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			worker(ctx, p.jobQueue, p.completions, p.emitter)
+		}()
 	}
-	p.eg = eg
-	return nil
-}
-
-func (p *workerPool) NumRunningNodes() int {
-	return len(p.runningNodes)
-}
-
-func (p *workerPool) AppendRunningNode(runningNode runningNode) {
-	p.runningNodes = append(p.runningNodes, runningNode)
 }
 
-func (p *workerPool) PopRunningNode() runningNode {
-	runningNode := p.runningNodes[0]
-	p.runningNodes = p.runningNodes[1:]
-	return runningNode
-}
-
-func (p *workerPool) AddNodeJob(logger *zap.Logger, nodeHash string, node Node, args map[string]any) {
-	resultChan := make(chan map[string]any)
+func (p *workerPool) AddNodeJob(logger *zap.Logger, nodeHash string, node Node, options NodeOptions, args map[string]any) {
 	p.jobQueue <- nodeJob{
-		Args:   args,
-		Node:   node,
-		Logger: logger,
-		Result: resultChan,
-	}
-	p.runningNodes = append(p.runningNodes, runningNode{
 		NodeHash: nodeHash,
-		Result:   resultChan,
-	})
+		Args:     args,
+		Node:     node,
+		Options:  options,
+		Logger:   logger,
+	}
 }
 
-func (p *workerPool) Stop() {
-	for i := 0; i < p.numWorkers; i++ {
-		p.doneChan <- true
-	}
+// Completions returns the channel every node result is sent on.
+func (p *workerPool) Completions() <-chan nodeCompletion {
+	return p.completions
 }
 
-func (p *workerPool) HasErr() <-chan error {
-	return p.errChan
+// Stop lets the workers exit once the queued jobs are done, or dropped if the context of the pool is done.
+// No job can be added after Stop.
+func (p *workerPool) Stop() {
+	close(p.jobQueue)
 }
 
-func (p *workerPool) Wait() error {
-	if p.eg == nil {
-		return nil
-	}
-	if err := p.eg.Wait(); err != nil {
-		return err
-	}
-	return nil
+// Wait blocks until every worker has exited.
+func (p *workerPool) Wait() {
+	p.wg.Wait()
 }
 
-func worker(ctx context.Context, done <-chan bool, jobQueue <-chan nodeJob, errChan chan<- error) error {
+func worker(ctx context.Context, jobQueue <-chan nodeJob, completions chan<- nodeCompletion, emitter *emitter) {
 	for {
 		select {
-		case <-done:
-			return nil
-		case job := <-jobQueue:
-			node := job.Node
-			res, err := node.Run(ctx, job.Logger, job.Args)
-			if err != nil {
-				errChan <- err
-				return err
-			}
-			job.Result <- res
-
 		case <-ctx.Done():
-			return ctx.Err()
+			return
+		case job, ok := <-jobQueue:
+			if !ok {
+				return
+			}
+			// select picks randomly between ready cases, a queued job must not run once the context is done
+			if ctx.Err() != nil {
+				return
+			}
+			start := time.Now()
+			completion := nodeCompletion{NodeHash: job.NodeHash}
+			completion.Result, completion.Attempts, completion.Err = runNode(ctx, job, emitter)
+			completion.Latency = time.Since(start)
+			if router, ok := job.Node.(Router); ok && completion.Err == nil {
+				completion.Routes, completion.Err = router.Route(completion.Result)
+				if completion.Err == nil && completion.Routes == nil {
+					completion.Routes = []string{}
+				}
+			}
+			completions <- completion
 		}
 	}
 }
@@ -0,0 +1,218 @@
+package scrolls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dskart/gollum/openai"
+)
+
+type AggregateType string
+
+const (
+	// MajorityAggregateType selects the most frequent answer. Outputs are compared after parsing,
+	// texts are compared case and whitespace insensitively.
+	MajorityAggregateType AggregateType = "majority"
+	// LogprobAggregateType selects the answer with the highest mean token log probability.
+	LogprobAggregateType AggregateType = "logprob"
+	// JudgeAggregateType lets a judge scroll select the best answer.
+	JudgeAggregateType AggregateType = "judge"
+)
+
+// JudgeOutputName is the output a judge scroll must generate: the 1-based number of the best candidate.
+const JudgeOutputName = "best"
+
+// Aggregate selects one of the n choices generated for a gen action.
+type Aggregate struct {
+	Type AggregateType `json:"type"`
+	// Judge is the name of the judge scroll registered with WithJudge, it is only used by judge aggregates.
+	Judge string `json:"judge,omitempty"`
+}
+
+func (a Aggregate) Validate() error {
+	switch a.Type {
+	case MajorityAggregateType, LogprobAggregateType:
+		if a.Judge != "" {
+			return fmt.Errorf("%s aggregates don't use a judge", a.Type)
+		}
+	case JudgeAggregateType:
+		if a.Judge == "" {
+			return fmt.Errorf("judge aggregates require a judge")
+		}
+	default:
+		return fmt.Errorf("unknown aggregate type %q", a.Type)
+	}
+	return nil
+}
+
+// Candidate is one of the choices generated for a gen action.
+type Candidate struct {
+	Output string `json:"output"`
+	// Value is the output as parsed by the gen parser, or the output itself if the gen has no parser
+	Value      any    `json:"value,omitempty"`
+	ParseError string `json:"parse_error,omitempty"`
+	// MeanLogprob is only set when the llm returned logprobs
+	MeanLogprob *float64 `json:"mean_logprob,omitempty"`
+	// Votes is the number of candidates with the same answer, it is only set by majority aggregates
+	Votes    int  `json:"votes,omitempty"`
+	Selected bool `json:"selected,omitempty"`
+
+	parseErr error
+}
+
+// WithJudge registers a judge scroll that gen actions can aggregate their choices with:
+//
+//	{"action": "gen", "output_name": "answer", "n": 5, "aggregate": {"type": "judge", "judge": "pick_best"}}
+//
+// The judge is executed with the "candidates" ([]string) and the "messages" ([]openai.Message) the gen
+// was prompted with, and must generate the 1-based number of the best candidate as its "best" output.
+func WithJudge(name string, judge *Scroll) func(*Options) {
+	return func(opts *Options) {
+		if opts.judges == nil {
+			opts.judges = make(map[string]*Scroll)
+		}
+		opts.judges[name] = judge
+	}
+}
+
+func (s *Scroll) validateAggregate(a Aggregate) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if a.Type == JudgeAggregateType {
+		if _, ok := s.judges[a.Judge]; !ok {
+			return fmt.Errorf("unknown judge %q", a.Judge)
+		}
+	}
+	return nil
+}
+
+// candidates parses the content of every choice.
+func (a AssistantBody) candidates(choices []openai.Choice) []Candidate {
+	candidates := make([]Candidate, 0, len(choices))
+	for _, choice := range choices {
+		if choice.Message.Content == nil {
+			continue
+		}
+
+		candidate := Candidate{Output: *choice.Message.Content, Value: *choice.Message.Content}
+		if mean, ok := choice.MeanLogprob(); ok {
+			candidate.MeanLogprob = &mean
+		}
+		if a.Parser != nil {
+			candidate.Value, candidate.parseErr = a.Parser.Parse(candidate.Output)
+			if candidate.parseErr != nil {
+				candidate.ParseError = candidate.parseErr.Error()
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// selectCandidate returns the index of the selected candidate among the ones that could be parsed,
+// along with the usage of the judge if there is one.
+func (a AssistantBody) selectCandidate(ctx context.Context, msgs []openai.Message, candidates []Candidate, judges map[string]*Scroll) (int, openai.Usage, error) {
+	valid := make([]int, 0, len(candidates))
+	for i, candidate := range candidates {
+		if candidate.parseErr == nil {
+			valid = append(valid, i)
+		}
+	}
+
+	if a.Aggregate == nil {
+		return valid[0], openai.Usage{}, nil
+	}
+
+	switch a.Aggregate.Type {
+	case MajorityAggregateType:
+		i, err := majorityVote(candidates, valid)
+		return i, openai.Usage{}, err
+	case LogprobAggregateType:
+		best := -1
+		for _, i := range valid {
+			if candidates[i].MeanLogprob == nil {
+				return 0, openai.Usage{}, fmt.Errorf("no logprobs returned for %s", a.OutputName)
+			}
+			if best < 0 || *candidates[i].MeanLogprob > *candidates[best].MeanLogprob {
+				best = i
+			}
+		}
+		return best, openai.Usage{}, nil
+	default:
+		return judge(ctx, judges[a.Aggregate.Judge], msgs, candidates, valid)
+	}
+}
+
+func majorityVote(candidates []Candidate, valid []int) (int, error) {
+	keys := make([]string, len(candidates))
+	votes := make(map[string]int, len(valid))
+	for _, i := range valid {
+		key, err := voteKey(candidates[i].Value)
+		if err != nil {
+			return 0, err
+		}
+		keys[i] = key
+		votes[key]++
+	}
+
+	// ties go to the first candidate
+	best := valid[0]
+	for _, i := range valid {
+		candidates[i].Votes = votes[keys[i]]
+		if votes[keys[i]] > votes[keys[best]] {
+			best = i
+		}
+	}
+	return best, nil
+}
+
+// voteKey returns the key candidates are compared with: texts are compared case and whitespace
+// insensitively and other parsed values by their json encoding.
+func voteKey(value any) (string, error) {
+	if text, ok := value.(string); ok {
+		return strings.Join(strings.Fields(strings.ToLower(text)), " "), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not compare candidates: %w", err)
+	}
+	return string(data), nil
+}
+
+var judgeNumberRe = regexp.MustCompile(`\d+`)
+
+func judge(ctx context.Context, judge *Scroll, msgs []openai.Message, candidates []Candidate, valid []int) (int, openai.Usage, error) {
+	if len(valid) == 1 {
+		return valid[0], openai.Usage{}, nil
+	}
+
+	outputs := make([]string, len(valid))
+	for i, c := range valid {
+		outputs[i] = candidates[c].Output
+	}
+
+	exec, err := judge.execute(ctx, map[string]any{"candidates": outputs, "messages": msgs})
+	if err != nil {
+		usage := openai.Usage{}
+		if exec != nil {
+			usage = exec.usage
+		}
+		return 0, usage, fmt.Errorf("judge failed: %w", err)
+	}
+
+	best, ok := exec.outputs[JudgeOutputName]
+	if !ok {
+		return 0, exec.usage, fmt.Errorf("judge did not generate a %q output", JudgeOutputName)
+	}
+	n, err := strconv.Atoi(judgeNumberRe.FindString(best))
+	if err != nil || n < 1 || n > len(valid) {
+		return 0, exec.usage, fmt.Errorf("judge answered %q, expected a candidate number between 1 and %d", best, len(valid))
+	}
+	return valid[n-1], exec.usage, nil
+}
@@ -0,0 +1,119 @@
+package scrolls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// choicesLLM answers every request with one choice per response, choices get the given mean logprobs if set.
+type choicesLLM struct {
+	responses []string
+	logprobs  []float64
+}
+
+func (l *choicesLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	resp := openai.ChatCompletionObject{Usage: openai.Usage{PromptTokens: 10, CompletionTokens: len(l.responses), TotalTokens: 10 + len(l.responses)}}
+	for i, response := range l.responses {
+		choice := openai.Choice{
+			Index:        i,
+			FinishReason: openai.StopFinishReasonType,
+			Message:      openai.ChatCompletionMessage{Role: openai.AssistantRoleType, Content: openai.StrPtr(response)},
+		}
+		if l.logprobs != nil {
+			choice.Logprobs = &openai.Logprobs{Content: []openai.TokenLogprob{{Token: response, Logprob: l.logprobs[i]}}}
+		}
+		resp.Choices = append(resp.Choices, choice)
+	}
+	return resp, nil
+}
+
+func aggregateTemplate(body string) string {
+	return "[[#user~]]\nHow many r are in strawberry?\n[[~/user]]\n[[#assistant~]]\n" + body + "\n[[~/assistant]]"
+}
+
+func TestScroll_ExecuteAggregate(t *testing.T) {
+	t.Run("Majority", func(t *testing.T) {
+		llm := &choicesLLM{responses: []string{"2", " Three", "three\n", "3"}}
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 4, "aggregate": {"type": "majority"}}`), llm)
+
+		res, err := scroll.ExecuteResult(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, " Three", res.Outputs["answer"])
+
+		candidates := res.Candidates["answer"]
+		require.Len(t, candidates, 4)
+		assert.Equal(t, []int{1, 2, 2, 1}, []int{candidates[0].Votes, candidates[1].Votes, candidates[2].Votes, candidates[3].Votes})
+		assert.True(t, candidates[1].Selected)
+		assert.False(t, candidates[2].Selected)
+	})
+
+	t.Run("MajorityParsed", func(t *testing.T) {
+		llm := &choicesLLM{responses: []string{"3", "three", "3.0", "2"}}
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 4, "parser": {"type": "number"}, "aggregate": {"type": "majority"}}`), llm)
+
+		res, err := scroll.ExecuteResult(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, res.Values["answer"])
+		assert.NotEmpty(t, res.Candidates["answer"][1].ParseError)
+		assert.Equal(t, 2, res.Candidates["answer"][0].Votes)
+	})
+
+	t.Run("Logprob", func(t *testing.T) {
+		llm := &choicesLLM{responses: []string{"2", "3"}, logprobs: []float64{-1.2, -0.1}}
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 2, "aggregate": {"type": "logprob"}}`), llm)
+
+		_, outputs, err := scroll.Execute(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "3", outputs["answer"])
+	})
+
+	t.Run("LogprobMissing", func(t *testing.T) {
+		llm := &choicesLLM{responses: []string{"2", "3"}}
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 2, "aggregate": {"type": "logprob"}}`), llm)
+
+		_, _, err := scroll.Execute(context.Background(), nil)
+		require.ErrorContains(t, err, "no logprobs")
+	})
+
+	t.Run("Judge", func(t *testing.T) {
+		judgeLLM := &scriptedLLM{responses: []string{"Candidate 2 is right."}}
+		judgeScroll := New(`[[#user~]]
+Which answer is right?
+{{range .candidates}}- {{.}}
+{{end}}
+[[~/user]]
+[[#assistant~]]
+{"action": "gen", "output_name": "best"}
+[[~/assistant]]`, judgeLLM)
+
+		llm := &choicesLLM{responses: []string{"2", "3"}}
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 2, "aggregate": {"type": "judge", "judge": "pick"}}`), llm, WithJudge("pick", judgeScroll))
+
+		var events []Event
+		res, err := scroll.ExecuteResult(context.Background(), nil, WithEventHandler(func(e Event) { events = append(events, e) }))
+		require.NoError(t, err)
+		assert.Equal(t, "3", res.Outputs["answer"])
+		require.Len(t, judgeLLM.requests, 1)
+		assert.Contains(t, *judgeLLM.requests[0][0].Content, "- 2\n- 3")
+
+		var finished *Event
+		for i := range events {
+			if events[i].Type == GenFinishedEventType {
+				finished = &events[i]
+			}
+		}
+		require.NotNil(t, finished)
+		assert.Len(t, finished.Candidates, 2)
+	})
+
+	t.Run("UnknownJudge", func(t *testing.T) {
+		scroll := New(aggregateTemplate(`{"action": "gen", "output_name": "answer", "n": 2, "aggregate": {"type": "judge", "judge": "pick"}}`), &choicesLLM{})
+		require.ErrorContains(t, scroll.Validate(nil), `unknown judge "pick"`)
+		_, _, err := scroll.Execute(context.Background(), nil)
+		require.ErrorContains(t, err, `unknown judge "pick"`)
+	})
+}
@@ -1,24 +1,37 @@
 package scrolls
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/dskart/gollum/openai"
 )
 
 type AssistantBody struct {
-	Action           string              `json:"action"`
-	OutputName       string              `json:"output_name"`
-	FrequencyPenalty *float64            `json:"frequency_penalty,omitempty"`
-	LogitBias        *map[string]float64 `json:"logit_bias,omitempty"`
-	MaxToken         *int                `json:"max_tokens,omitempty"`
-	N                *int                `json:"n,omitempty"`
-	PresencePenalty  *float64            `json:"presence_penalty,omitempty"`
-	Stop             *[]string           `json:"stop,omitempty"`
-	Temperature      *float64            `json:"temperature,omitempty"`
-	TopP             *float64            `json:"top_p,omitempty"`
-	User             *string             `json:"user,omitempty"`
+	Action           string                 `json:"action"`
+	OutputName       string                 `json:"output_name"`
+	Model            *string                `json:"model,omitempty"`
+	FrequencyPenalty *float64               `json:"frequency_penalty,omitempty"`
+	LogitBias        *map[string]float64    `json:"logit_bias,omitempty"`
+	MaxToken         *int                   `json:"max_tokens,omitempty"`
+	N                *int                   `json:"n,omitempty"`
+	PresencePenalty  *float64               `json:"presence_penalty,omitempty"`
+	ResponseFormat   *openai.ResponseFormat `json:"response_format,omitempty"`
+	Seed             *int                   `json:"seed,omitempty"`
+	Stop             *[]string              `json:"stop,omitempty"`
+	Temperature      *float64               `json:"temperature,omitempty"`
+	TopP             *float64               `json:"top_p,omitempty"`
+	Tools            *[]openai.Tool         `json:"tools,omitempty"`
+	ToolChoice       *string                `json:"tool_choice,omitempty"`
+	User             *string                `json:"user,omitempty"`
+	Parser           *Parser                `json:"parser,omitempty"`
+	// Aggregate selects one of the n choices, by default the first choice that can be parsed is used.
+	Aggregate *Aggregate `json:"aggregate,omitempty"`
+	// Parallel leaves the gen right before it out of the history of the gen, both are prompted
+	// concurrently unless a block in the history references the output of the other.
+	Parallel bool `json:"parallel,omitempty"`
 }
 
 type ActionType string
@@ -27,8 +40,32 @@ const (
 	GenActionType string = "gen"
 )
 
+// WithDefaults returns a copy of the assistant body where every unset prompt option is taken from defaults.
+func (a AssistantBody) WithDefaults(defaults AssistantBody) AssistantBody {
+	ret := a
+	ret.Model = cmp.Or(a.Model, defaults.Model)
+	ret.FrequencyPenalty = cmp.Or(a.FrequencyPenalty, defaults.FrequencyPenalty)
+	ret.LogitBias = cmp.Or(a.LogitBias, defaults.LogitBias)
+	ret.MaxToken = cmp.Or(a.MaxToken, defaults.MaxToken)
+	ret.N = cmp.Or(a.N, defaults.N)
+	ret.PresencePenalty = cmp.Or(a.PresencePenalty, defaults.PresencePenalty)
+	ret.ResponseFormat = cmp.Or(a.ResponseFormat, defaults.ResponseFormat)
+	ret.Seed = cmp.Or(a.Seed, defaults.Seed)
+	ret.Stop = cmp.Or(a.Stop, defaults.Stop)
+	ret.Temperature = cmp.Or(a.Temperature, defaults.Temperature)
+	ret.TopP = cmp.Or(a.TopP, defaults.TopP)
+	ret.Tools = cmp.Or(a.Tools, defaults.Tools)
+	ret.ToolChoice = cmp.Or(a.ToolChoice, defaults.ToolChoice)
+	ret.User = cmp.Or(a.User, defaults.User)
+	return ret
+}
+
 func (a AssistantBody) OpenAiPromptOptions() []func(*openai.ChatCompletionOptions) {
-	ret := make([]func(*openai.ChatCompletionOptions), 0, 9)
+	ret := make([]func(*openai.ChatCompletionOptions), 0, 14)
+	if a.Model != nil {
+		ret = append(ret, openai.WithModel(*a.Model))
+	}
+
 	if a.FrequencyPenalty != nil {
 		ret = append(ret, openai.WithFrequencyPenalty(*a.FrequencyPenalty))
 	}
@@ -49,6 +86,14 @@ func (a AssistantBody) OpenAiPromptOptions() []func(*openai.ChatCompletionOption
 		ret = append(ret, openai.WithPresencyPenalty(*a.PresencePenalty))
 	}
 
+	if a.ResponseFormat != nil {
+		ret = append(ret, openai.WithResponseFormat(*a.ResponseFormat))
+	}
+
+	if a.Seed != nil {
+		ret = append(ret, openai.WithSeed(*a.Seed))
+	}
+
 	if a.Stop != nil {
 		ret = append(ret, openai.WithStop(*a.Stop))
 	}
@@ -61,6 +106,14 @@ func (a AssistantBody) OpenAiPromptOptions() []func(*openai.ChatCompletionOption
 		ret = append(ret, openai.WithTopP(*a.TopP))
 	}
 
+	if a.Tools != nil {
+		ret = append(ret, openai.WithTools(*a.Tools))
+	}
+
+	if a.ToolChoice != nil {
+		ret = append(ret, openai.WithToolChoice(*a.ToolChoice))
+	}
+
 	if a.User != nil {
 		ret = append(ret, openai.WithUser(*a.User))
 	}
@@ -68,19 +121,101 @@ func (a AssistantBody) OpenAiPromptOptions() []func(*openai.ChatCompletionOption
 	return ret
 }
 
-func promptOpenAi(ctx context.Context, llm openai.OpenAi, msgs []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (string, error) {
-	resp, err := llm.ChatCompletionCreate(ctx, msgs, opts...)
+type genResult struct {
+	resp       string
+	value      any
+	usage      openai.Usage
+	candidates []Candidate
+}
+
+// gen prompts the llm and, if the assistant body has a parser, parses the response.
+// When no choice can be parsed the model is re-asked with the parse error until the parser runs out of retries.
+// If onDelta is set, the first choice is streamed to it as it is generated.
+func (a AssistantBody) gen(ctx context.Context, llm openai.OpenAi, msgs []openai.Message, onDelta func(string), judges map[string]*Scroll) (genResult, error) {
+	opts := a.OpenAiPromptOptions()
+	if a.Aggregate != nil && a.Aggregate.Type == LogprobAggregateType {
+		opts = append(opts, openai.WithLogprobs(true))
+	}
+
+	var res genResult
+	history := slices.Clone(msgs)
+	for attempt := 0; ; attempt++ {
+		choices, usage, err := promptOpenAi(ctx, llm, history, onDelta, opts...)
+		if err != nil {
+			return res, fmt.Errorf("failed to prompt openai: %w", err)
+		}
+		res.usage = addUsage(res.usage, usage)
+		res.candidates = a.candidates(choices)
+
+		if slices.ContainsFunc(res.candidates, func(c Candidate) bool { return c.parseErr == nil }) {
+			selected, judgeUsage, err := a.selectCandidate(ctx, history, res.candidates, judges)
+			res.usage = addUsage(res.usage, judgeUsage)
+			if err != nil {
+				return res, fmt.Errorf("could not select %s output: %w", a.OutputName, err)
+			}
+			res.candidates[selected].Selected = true
+			res.resp = res.candidates[selected].Output
+			res.value = res.candidates[selected].Value
+			return res, nil
+		}
+
+		first := res.candidates[0]
+		res.resp = first.Output
+		if attempt >= a.Parser.maxRetries() {
+			return res, fmt.Errorf("could not parse %s output %q: %w", a.OutputName, first.Output, first.parseErr)
+		}
+
+		history = append(history,
+			openai.Message{Role: openai.AssistantRoleType, Content: openai.StrPtr(first.Output)},
+			openai.Message{Role: openai.UserRoleType, Content: openai.StrPtr(reAskPrompt(a.Parser.Type, first.parseErr))},
+		)
+	}
+}
+
+func reAskPrompt(parserType ParserType, err error) string {
+	return fmt.Sprintf("Your previous response could not be parsed as %s: %v\nRespond again with only the corrected %s output.", parserType, err, parserType)
+}
+
+func addUsage(a, b openai.Usage) openai.Usage {
+	return openai.Usage{
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
+
+// promptOpenAi returns the choices of the completion that have content.
+func promptOpenAi(ctx context.Context, llm openai.OpenAi, msgs []openai.Message, onDelta func(string), opts ...func(*openai.ChatCompletionOptions)) ([]openai.Choice, openai.Usage, error) {
+	var resp openai.ChatCompletionObject
+	var err error
+	if streamer, ok := llm.(openai.OpenAiStreamer); ok && onDelta != nil {
+		resp, err = streamer.ChatCompletionStream(ctx, msgs, func(chunk openai.ChatCompletionChunk) error {
+			for _, c := range chunk.Choices {
+				if c.Index == 0 && c.Delta.Content != nil && *c.Delta.Content != "" {
+					onDelta(*c.Delta.Content)
+				}
+			}
+			return nil
+		}, opts...)
+	} else {
+		resp, err = llm.ChatCompletionCreate(ctx, msgs, opts...)
+	}
 	if err != nil {
-		return "", err
+		return nil, openai.Usage{}, err
 	}
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned")
+		return nil, openai.Usage{}, fmt.Errorf("no choices returned")
 	}
 
-	choice := resp.Choices[0]
-	if choice.Message.Content == nil {
-		return "", fmt.Errorf("no content in choice")
+	choices := slices.DeleteFunc(slices.Clone(resp.Choices), func(c openai.Choice) bool {
+		return c.Message.Content == nil
+	})
+	if len(choices) == 0 {
+		return nil, openai.Usage{}, fmt.Errorf("no content in choice")
+	}
+	if _, ok := llm.(openai.OpenAiStreamer); !ok && onDelta != nil {
+		// the llm can't stream so the whole response is sent as a single delta
+		onDelta(*choices[0].Message.Content)
 	}
-	choiceContent := *choice.Message.Content
-	return choiceContent, nil
+	return choices, resp.Usage, nil
 }
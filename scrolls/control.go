@@ -0,0 +1,203 @@
+package scrolls
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type DirectiveType string
+
+const (
+	IfDirectiveType      DirectiveType = "if"
+	ElseDirectiveType    DirectiveType = "else"
+	EndIfDirectiveType   DirectiveType = "endif"
+	LoopDirectiveType    DirectiveType = "loop"
+	EndLoopDirectiveType DirectiveType = "endloop"
+)
+
+// directiveRe matches the control flow directives evaluated while the scroll executes.
+var directiveRe = regexp.MustCompile(`(?s)\[\[#(if|else|endif|loop|endloop)~\]\](.*?)\[\[~/(if|else|endif|loop|endloop)\]\]`)
+
+// Condition tests the raw output of a gen action, surrounding whitespace is ignored. Exactly one test must be set.
+type Condition struct {
+	Output    string  `json:"output"`
+	Equals    *string `json:"equals,omitempty"`
+	NotEquals *string `json:"not_equals,omitempty"`
+	Contains  *string `json:"contains,omitempty"`
+	Matches   *string `json:"matches,omitempty"`
+}
+
+func (c Condition) Validate() error {
+	if c.Output == "" {
+		return fmt.Errorf("condition without output")
+	}
+
+	n := 0
+	for _, test := range []*string{c.Equals, c.NotEquals, c.Contains, c.Matches} {
+		if test != nil {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("condition on %s must have exactly one of equals, not_equals, contains or matches", c.Output)
+	}
+
+	if c.Matches != nil {
+		if _, err := regexp.Compile(*c.Matches); err != nil {
+			return fmt.Errorf("invalid pattern for %s: %w", c.Output, err)
+		}
+	}
+	return nil
+}
+
+func (c Condition) eval(outputs map[string]string) (bool, error) {
+	output, ok := outputs[c.Output]
+	if !ok {
+		return false, fmt.Errorf("condition on %s: output has not been generated", c.Output)
+	}
+	output = strings.TrimSpace(output)
+
+	switch {
+	case c.Equals != nil:
+		return output == *c.Equals, nil
+	case c.NotEquals != nil:
+		return output != *c.NotEquals, nil
+	case c.Contains != nil:
+		return strings.Contains(output, *c.Contains), nil
+	default:
+		return regexp.MustCompile(*c.Matches).MatchString(output), nil
+	}
+}
+
+// LoopBody is the body of a loop directive. The loop runs at most MaxIterations times
+// and stops early once its Until condition holds after an iteration.
+type LoopBody struct {
+	MaxIterations int        `json:"max_iterations"`
+	Until         *Condition `json:"until,omitempty"`
+}
+
+// directive is a control flow block found before the message at index.
+type directive struct {
+	typ       DirectiveType
+	index     int
+	condition Condition
+	loop      LoopBody
+}
+
+func parseDirective(typ DirectiveType, index int, content string) (directive, error) {
+	d := directive{typ: typ, index: index}
+	content = strings.TrimSpace(content)
+
+	switch typ {
+	case IfDirectiveType:
+		if err := json.Unmarshal([]byte(content), &d.condition); err != nil {
+			return directive{}, fmt.Errorf("could not unmarshal condition: %w", err)
+		}
+		if err := d.condition.Validate(); err != nil {
+			return directive{}, err
+		}
+	case LoopDirectiveType:
+		if err := json.Unmarshal([]byte(content), &d.loop); err != nil {
+			return directive{}, fmt.Errorf("could not unmarshal loop: %w", err)
+		}
+		if d.loop.MaxIterations <= 0 {
+			return directive{}, fmt.Errorf("loops require a positive max_iterations")
+		}
+		if d.loop.Until != nil {
+			if err := d.loop.Until.Validate(); err != nil {
+				return directive{}, err
+			}
+		}
+	default:
+		if content != "" {
+			return directive{}, fmt.Errorf("%s directives have no body", typ)
+		}
+	}
+	return d, nil
+}
+
+// step is a node of the control flow tree of a scroll, it is either a message, an if or a loop.
+type step struct {
+	// index of the message, only set on message steps
+	index int
+
+	directive *directive
+	// then holds the steps of the if branch or the loop body
+	then []step
+	// otherwise holds the steps of the else branch
+	otherwise []step
+}
+
+// buildSteps builds the control flow tree of n messages interleaved with the directives.
+func buildSteps(n int, directives []directive) ([]step, error) {
+	b := stepBuilder{n: n, directives: directives}
+	steps, end, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, fmt.Errorf("unexpected %s directive", end.typ)
+	}
+	return steps, nil
+}
+
+type stepBuilder struct {
+	n          int
+	directives []directive
+	msg        int
+	next       int
+}
+
+// build consumes steps until it reaches an else, endif or endloop directive or the end of the scroll.
+// It returns the directive it stopped at, or nil at the end of the scroll.
+func (b *stepBuilder) build() ([]step, *directive, error) {
+	steps := make([]step, 0)
+	for {
+		// directives found before a message come first
+		if b.next < len(b.directives) && b.directives[b.next].index <= b.msg {
+			d := &b.directives[b.next]
+			b.next++
+
+			switch d.typ {
+			case ElseDirectiveType, EndIfDirectiveType, EndLoopDirectiveType:
+				return steps, d, nil
+			case IfDirectiveType:
+				s := step{directive: d}
+				var end *directive
+				var err error
+				if s.then, end, err = b.build(); err != nil {
+					return nil, nil, err
+				}
+				if end != nil && end.typ == ElseDirectiveType {
+					if s.otherwise, end, err = b.build(); err != nil {
+						return nil, nil, err
+					}
+				}
+				if end == nil || end.typ != EndIfDirectiveType {
+					return nil, nil, fmt.Errorf("if directive on %s is not closed by endif", d.condition.Output)
+				}
+				steps = append(steps, s)
+			case LoopDirectiveType:
+				s := step{directive: d}
+				var end *directive
+				var err error
+				if s.then, end, err = b.build(); err != nil {
+					return nil, nil, err
+				}
+				if end == nil || end.typ != EndLoopDirectiveType {
+					return nil, nil, fmt.Errorf("loop directive is not closed by endloop")
+				}
+				steps = append(steps, s)
+			}
+			continue
+		}
+
+		if b.msg >= b.n {
+			return steps, nil, nil
+		}
+		steps = append(steps, step{index: b.msg})
+		b.msg++
+	}
+}
@@ -0,0 +1,135 @@
+package scrolls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIfTemplate = `[[#user~]]
+Classify this ticket: {{.ticket}}
+[[~/user]]
+[[#assistant~]]
+{"action": "gen", "output_name": "category"}
+[[~/assistant]]
+[[#if~]]{"output": "category", "equals": "billing"}[[~/if]]
+[[#user~]]
+Which invoice is it about?
+[[~/user]]
+[[#else~]][[~/else]]
+[[#user~]]
+Summarize the ticket.
+[[~/user]]
+[[#endif~]][[~/endif]]
+[[#assistant~]]
+{"action": "gen", "output_name": "follow_up"}
+[[~/assistant]]`
+
+const testLoopTemplate = `[[#user~]]
+Write a haiku about {{.topic}}.
+[[~/user]]
+[[#loop~]]{"max_iterations": 3, "until": {"output": "review", "contains": "DONE"}}[[~/loop]]
+[[#assistant~]]
+{"action": "gen", "output_name": "draft"}
+[[~/assistant]]
+[[#user~]]
+Review the haiku, answer DONE if it is good.
+[[~/user]]
+[[#assistant~]]
+{"action": "gen", "output_name": "review"}
+[[~/assistant]]
+[[#endloop~]][[~/endloop]]`
+
+func TestScroll_ExecuteIf(t *testing.T) {
+	testCases := []struct {
+		name     string
+		category string
+		question string
+	}{
+		{name: "Then", category: "billing", question: "Which invoice is it about?"},
+		{name: "Else", category: " bug\n", question: "Summarize the ticket."},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			llm := &scriptedLLM{responses: []string{tc.category, "ok"}}
+			msgs, outputs, err := New(testIfTemplate, llm).Execute(context.Background(), map[string]any{"ticket": "I was charged twice"})
+			require.NoError(t, err)
+
+			require.Len(t, msgs, 4)
+			assert.Equal(t, tc.question, *msgs[2].Content)
+			assert.Equal(t, "ok", outputs["follow_up"])
+		})
+	}
+}
+
+func TestScroll_ExecuteLoop(t *testing.T) {
+	t.Run("Until", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"draft 1", "too long", "draft 2", "DONE"}}
+		msgs, values, err := New(testLoopTemplate, llm).ExecuteParsed(context.Background(), map[string]any{"topic": "autumn"})
+		require.NoError(t, err)
+
+		assert.Len(t, msgs, 7)
+		assert.Equal(t, []any{"draft 1", "draft 2"}, values["draft"])
+		assert.Equal(t, []any{"too long", "DONE"}, values["review"])
+		// the second draft is prompted with the first review in its history
+		require.Len(t, llm.requests, 4)
+		assert.Equal(t, "too long", *llm.requests[2][3].Content)
+	})
+
+	t.Run("MaxIterations", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"a", "no", "b", "no", "c", "no", "d", "no"}}
+		_, outputs, err := New(testLoopTemplate, llm).Execute(context.Background(), map[string]any{"topic": "autumn"})
+		require.NoError(t, err)
+
+		assert.Len(t, llm.requests, 6)
+		// every iteration is returned, not only the last one
+		assert.JSONEq(t, `["a", "b", "c"]`, outputs["draft"])
+		assert.JSONEq(t, `["no", "no", "no"]`, outputs["review"])
+	})
+}
+
+func TestScroll_ParseBlocksSkipsDirectives(t *testing.T) {
+	msgs, err := New(testLoopTemplate, nil).ParseBlocks(map[string]any{"topic": "autumn"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	for _, msg := range msgs {
+		assert.NotContains(t, *msg.Content, "[[#")
+	}
+	assert.Equal(t, openai.UserRoleType, msgs[0].Role)
+}
+
+func TestScroll_InvalidDirectives(t *testing.T) {
+	const user = "[[#user~]]hi[[~/user]]\n"
+	const gen = `[[#assistant~]]{"action": "gen", "output_name": "answer"}[[~/assistant]]` + "\n"
+	testCases := []struct {
+		name string
+		text string
+	}{
+		{name: "UnclosedIf", text: user + gen + `[[#if~]]{"output": "answer", "equals": "yes"}[[~/if]]` + user},
+		{name: "UnclosedLoop", text: `[[#loop~]]{"max_iterations": 2}[[~/loop]]` + user},
+		{name: "UnexpectedEndIf", text: user + "[[#endif~]][[~/endif]]"},
+		{name: "MismatchedEnd", text: `[[#loop~]]{"max_iterations": 2}[[~/loop]]` + user + "[[#endif~]][[~/endif]]"},
+		{name: "UnboundedLoop", text: `[[#loop~]]{}[[~/loop]]` + user + "[[#endloop~]][[~/endloop]]"},
+		{name: "ConditionWithoutTest", text: user + gen + `[[#if~]]{"output": "answer"}[[~/if]]` + user + "[[#endif~]][[~/endif]]"},
+		{name: "InvalidPattern", text: user + gen + `[[#if~]]{"output": "answer", "matches": "("}[[~/if]]` + user + "[[#endif~]][[~/endif]]"},
+		{name: "InsideMessage", text: "[[#user~]]hi [[#endif~]][[~/endif]][[~/user]]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.text, nil).ParseBlocks(nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestScroll_ConditionOnMissingOutput(t *testing.T) {
+	text := `[[#user~]]hi[[~/user]]
+[[#if~]]{"output": "answer", "matches": "^yes"}[[~/if]]
+[[#user~]]yes[[~/user]]
+[[#endif~]][[~/endif]]`
+	_, _, err := New(text, &scriptedLLM{}).Execute(context.Background(), nil)
+	require.ErrorContains(t, err, "output has not been generated")
+}
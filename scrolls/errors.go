@@ -0,0 +1,5 @@
+package scrolls
+
+import "errors"
+
+var ErrTokenBudgetExceeded = errors.New("token budget exceeded")
@@ -0,0 +1,110 @@
+package scrolls
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dskart/gollum/openai"
+)
+
+type EventType string
+
+const (
+	TruncatedEventType     EventType = "truncated"
+	BlockRenderedEventType EventType = "block_rendered"
+	GenStartedEventType    EventType = "gen_started"
+	TokenDeltaEventType    EventType = "token_delta"
+	GenFinishedEventType   EventType = "gen_finished"
+	ErrorEventType         EventType = "error"
+	DoneEventType          EventType = "done"
+)
+
+// Event is emitted while a scroll executes. Which fields are set depends on the event type.
+type Event struct {
+	Type EventType `json:"type"`
+	// Index is the index of the block in the rendered scroll
+	Index      int             `json:"index"`
+	Message    *openai.Message `json:"message,omitempty"`
+	OutputName string          `json:"output_name,omitempty"`
+	Delta      string          `json:"delta,omitempty"`
+	Output     string          `json:"output,omitempty"`
+	Usage      *openai.Usage   `json:"usage,omitempty"`
+	Latency    time.Duration   `json:"latency,omitempty"`
+	// Candidates is only set on gen finished events of gens that generated more than one choice
+	Candidates []Candidate `json:"candidates,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// Truncation is only set on truncated events
+	Truncation *Truncation `json:"truncation,omitempty"`
+	// Outputs is only set on done events
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// VariantId is set when the scroll is executed as a variant of a ScrollSet
+	VariantId string `json:"variant_id,omitempty"`
+}
+
+type ExecuteOptions struct {
+	eventHandler func(Event)
+	variantId    string
+}
+
+func withVariantId(id string) func(*ExecuteOptions) {
+	return func(opts *ExecuteOptions) {
+		opts.variantId = id
+	}
+}
+
+// WithEventHandler calls handler for every event emitted during the execution.
+// Token deltas are streamed if the llm implements openai.OpenAiStreamer.
+// The handler is never called concurrently but it blocks the execution, so it should return quickly.
+func WithEventHandler(handler func(Event)) func(*ExecuteOptions) {
+	return func(opts *ExecuteOptions) {
+		opts.eventHandler = handler
+	}
+}
+
+type emitter struct {
+	mu        sync.Mutex
+	handler   func(Event)
+	variantId string
+}
+
+func (e *emitter) enabled() bool {
+	return e.handler != nil
+}
+
+func (e *emitter) emit(event Event) {
+	if e.handler == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event.VariantId = e.variantId
+	e.handler(event)
+}
+
+// ExecuteEvents executes the scroll in the background and returns its events over a channel.
+// The last event is either a done event holding the gen outputs or an error event, then the channel is closed.
+// The channel must be drained or ctx canceled for the execution to finish.
+func (s *Scroll) ExecuteEvents(ctx context.Context, args map[string]any, opts ...func(*ExecuteOptions)) <-chan Event {
+	events := make(chan Event)
+	send := func(event Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		opts = append(opts, WithEventHandler(send))
+		_, outputs, err := s.Execute(ctx, args, opts...)
+		if err != nil {
+			// the error event has already been emitted by the execution
+			return
+		}
+		send(Event{Type: DoneEventType, Outputs: outputs})
+	}()
+
+	return events
+}
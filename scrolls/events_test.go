@@ -0,0 +1,75 @@
+package scrolls
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScroll_ExecuteEvents(t *testing.T) {
+	ctx := context.Background()
+
+	llm := &scriptedLLM{responses: []string{"42"}}
+	scroll := New(testTemplate, llm)
+
+	events := make([]Event, 0)
+	for event := range scroll.ExecuteEvents(ctx, map[string]any{"query": "What is the meaning of life?"}) {
+		events = append(events, event)
+	}
+
+	types := make([]EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []EventType{
+		BlockRenderedEventType,
+		BlockRenderedEventType,
+		GenStartedEventType,
+		TokenDeltaEventType,
+		GenFinishedEventType,
+		DoneEventType,
+	}, types)
+
+	genFinished := events[4]
+	assert.Equal(t, 2, genFinished.Index)
+	assert.Equal(t, "response", genFinished.OutputName)
+	assert.Equal(t, "42", genFinished.Output)
+	require.NotNil(t, genFinished.Usage)
+	assert.Equal(t, map[string]string{"response": "42"}, events[5].Outputs)
+
+	t.Run("Error", func(t *testing.T) {
+		llm := &scriptedLLM{}
+		scroll := New(testTemplate, llm)
+
+		var last Event
+		for event := range scroll.ExecuteEvents(ctx, map[string]any{"query": "What is the meaning of life?"}) {
+			last = event
+		}
+		assert.Equal(t, ErrorEventType, last.Type)
+		assert.Contains(t, last.Error, "no scripted response left")
+	})
+}
+
+func TestEventStreamHandler(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{"42"}}
+	scroll := New(testTemplate, llm)
+
+	handler := EventStreamHandler(scroll, func(r *http.Request) (map[string]any, error) {
+		return map[string]any{"query": r.URL.Query().Get("query")}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?query=foo", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Equal(t, 6, strings.Count(body, "\n\n"))
+	assert.Contains(t, body, "event: token_delta\ndata: {\"type\":\"token_delta\",\"index\":2,\"output_name\":\"response\",\"delta\":\"42\"}\n\n")
+	assert.True(t, strings.HasSuffix(body, "event: done\ndata: {\"type\":\"done\",\"index\":0,\"outputs\":{\"response\":\"42\"}}\n\n"))
+}
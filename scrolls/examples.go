@@ -0,0 +1,240 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+// Example is a labeled input/output pair used for few-shot prompting.
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// ExampleStore selects the examples most relevant to a query.
+type ExampleStore interface {
+	// Select returns at most k examples, the most relevant first.
+	Select(ctx context.Context, query string, k int) ([]Example, error)
+}
+
+// Embedder turns texts into embedding vectors.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// MemoryExampleStore keeps examples in memory and ranks them by embedding cosine similarity
+// if it has an embedder, or by keyword overlap otherwise.
+type MemoryExampleStore struct {
+	embedder Embedder
+
+	mu         sync.RWMutex
+	examples   []Example
+	embeddings [][]float64
+}
+
+var _ ExampleStore = (*MemoryExampleStore)(nil)
+
+type ExampleStoreOptions struct {
+	embedder Embedder
+}
+
+func WithEmbedder(embedder Embedder) func(*ExampleStoreOptions) {
+	return func(opts *ExampleStoreOptions) {
+		opts.embedder = embedder
+	}
+}
+
+func NewMemoryExampleStore(opts ...func(*ExampleStoreOptions)) *MemoryExampleStore {
+	options := ExampleStoreOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &MemoryExampleStore{
+		embedder: options.embedder,
+	}
+}
+
+// Add adds examples to the store, their inputs are embedded if the store has an embedder.
+func (s *MemoryExampleStore) Add(ctx context.Context, examples ...Example) error {
+	var embeddings [][]float64
+	if s.embedder != nil {
+		inputs := make([]string, len(examples))
+		for i, example := range examples {
+			inputs[i] = example.Input
+		}
+
+		var err error
+		embeddings, err = s.embedder.Embed(ctx, inputs)
+		if err != nil {
+			return fmt.Errorf("could not embed examples: %w", err)
+		}
+		if len(embeddings) != len(examples) {
+			return fmt.Errorf("embedder returned %d embeddings for %d examples", len(embeddings), len(examples))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples = append(s.examples, examples...)
+	s.embeddings = append(s.embeddings, embeddings...)
+	return nil
+}
+
+func (s *MemoryExampleStore) Select(ctx context.Context, query string, k int) ([]Example, error) {
+	var queryEmbedding []float64
+	if s.embedder != nil {
+		embeddings, err := s.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("could not embed query: %w", err)
+		}
+		if len(embeddings) != 1 {
+			return nil, fmt.Errorf("embedder returned %d embeddings for 1 query", len(embeddings))
+		}
+		queryEmbedding = embeddings[0]
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type scoredExample struct {
+		index int
+		score float64
+	}
+	scored := make([]scoredExample, len(s.examples))
+	queryKeywords := keywords(query)
+	for i, example := range s.examples {
+		score := 0.0
+		if queryEmbedding != nil {
+			score = cosineSimilarity(queryEmbedding, s.embeddings[i])
+		} else {
+			score = keywordOverlap(queryKeywords, keywords(example.Input))
+		}
+		scored[i] = scoredExample{index: i, score: score}
+	}
+
+	// stable so ties keep the order the examples were added in
+	slices.SortStableFunc(scored, func(a, b scoredExample) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	ret := make([]Example, 0, min(k, len(scored)))
+	for _, se := range scored[:min(k, len(scored))] {
+		ret = append(ret, s.examples[se.index])
+	}
+	return ret, nil
+}
+
+func keywords(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	ret := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		ret[word] = struct{}{}
+	}
+	return ret
+}
+
+// keywordOverlap is the Jaccard index of two keyword sets.
+func keywordOverlap(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for word := range a {
+		if _, ok := b[word]; ok {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// WithExampleStore lets the scroll template select few-shot examples from store with the examples function:
+//
+//	{{examples .question 5 800}}
+//
+// selects the 5 examples most relevant to the question that fit in 800 tokens, the token budget is optional.
+// The examples are expanded into alternating user and assistant blocks, selecting an example whose text contains
+// a block tag is an error. Executions select examples with their context.
+func WithExampleStore(store ExampleStore) func(*Options) {
+	return func(opts *Options) {
+		opts.exampleStore = store
+	}
+}
+
+// exampleFuncs returns the examples function, ctx is the context of the render the examples are selected for.
+func (s *Scroll) exampleFuncs(ctx context.Context) template.FuncMap {
+	if s.exampleStore == nil {
+		return template.FuncMap{}
+	}
+
+	return template.FuncMap{
+		"examples": func(query string, k int, maxTokens ...int) (string, error) {
+			examples, err := s.exampleStore.Select(ctx, query, k)
+			if err != nil {
+				return "", fmt.Errorf("could not select examples: %w", err)
+			}
+			// examples are expanded into blocks, their text must not open or close blocks of its own
+			for i, example := range examples {
+				if hasBlockMarker(example.Input) || hasBlockMarker(example.Output) {
+					return "", fmt.Errorf("example %d contains a block marker", i)
+				}
+			}
+
+			budget := math.MaxInt
+			if len(maxTokens) > 0 {
+				budget = maxTokens[0]
+			}
+
+			var sb strings.Builder
+			used := 0
+			for _, example := range examples {
+				tokens := s.tokenizer.CountTokens(example.Input) + s.tokenizer.CountTokens(example.Output) + 2*messageTokenOverhead
+				if used+tokens > budget {
+					continue
+				}
+				used += tokens
+				fmt.Fprintf(&sb, "[[#user~]]\n%s\n[[~/user]]\n[[#assistant~]]\n%s\n[[~/assistant]]\n", example.Input, example.Output)
+			}
+			return sb.String(), nil
+		},
+	}
+}
+
+// hasBlockMarker returns whether the text contains the opening or closing tag of a block.
+func hasBlockMarker(text string) bool {
+	return strings.Contains(text, "[[#") || strings.Contains(text, "[[~/")
+}
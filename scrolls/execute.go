@@ -0,0 +1,190 @@
+package scrolls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/dskart/gollum/openai"
+)
+
+type execution struct {
+	msgs        []openai.Message
+	outputs     map[string]string
+	values      map[string]any
+	candidates  map[string][]Candidate
+	usage       openai.Usage
+	truncations []Truncation
+	events      *emitter
+
+	// loops is the number of loops the execution is currently in, gen outputs are accumulated into lists inside loops
+	loops int
+	// lists are the raw outputs of the gens accumulated inside loops, outputs only holds the last one
+	lists map[string][]string
+}
+
+func parseGenAction(msg openai.Message) (AssistantBody, bool) {
+	if msg.Role != openai.AssistantRoleType || msg.Content == nil || len(msg.ToolCalls) > 0 {
+		return AssistantBody{}, false
+	}
+
+	var assistantBody AssistantBody
+	if err := json.Unmarshal([]byte(*msg.Content), &assistantBody); err != nil {
+		// if we can't unmarshal the assistant body, then it's not an assistant action
+		return AssistantBody{}, false
+	}
+	// assistant messages of few-shot transcripts can hold json too, only gen actions are generated.
+	// Scrolls written before actions were introduced only set an output name, they still are gen actions.
+	switch assistantBody.Action {
+	case GenActionType:
+		return assistantBody, true
+	case "":
+		return assistantBody, assistantBody.OutputName != ""
+	default:
+		return AssistantBody{}, false
+	}
+}
+
+func (s *Scroll) execute(ctx context.Context, args map[string]any, opts ...func(*ExecuteOptions)) (*execution, error) {
+	options := ExecuteOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	events := &emitter{handler: options.eventHandler, variantId: options.variantId}
+
+	parsed, err := s.parse(ctx, args)
+	if err != nil {
+		err = fmt.Errorf("could not parse scroll: %w", err)
+		events.emit(Event{Type: ErrorEventType, Error: err.Error()})
+		return nil, err
+	}
+	blocks := parsed.msgs
+	for _, truncation := range parsed.truncations {
+		events.emit(Event{Type: TruncatedEventType, Truncation: &truncation})
+	}
+
+	exec := &execution{
+		msgs:        make([]openai.Message, 0, len(blocks)),
+		outputs:     make(map[string]string),
+		values:      make(map[string]any),
+		candidates:  make(map[string][]Candidate),
+		truncations: parsed.truncations,
+		events:      events,
+		lists:       make(map[string][]string),
+	}
+
+	steps, err := buildSteps(len(blocks), parsed.directives)
+	if err == nil {
+		err = s.runSteps(ctx, exec, parsed, steps)
+	}
+	if err != nil {
+		events.emit(Event{Type: ErrorEventType, Error: err.Error()})
+		return exec, err
+	}
+
+	return exec, nil
+}
+
+// runSteps runs the control flow tree of the scroll, directives are evaluated against the outputs generated so far.
+func (s *Scroll) runSteps(ctx context.Context, exec *execution, parsed parsedScroll, steps []step) error {
+	for i := 0; i < len(steps); {
+		if d := steps[i].directive; d != nil {
+			var err error
+			switch d.typ {
+			case IfDirectiveType:
+				err = s.runIf(ctx, exec, parsed, steps[i])
+			case LoopDirectiveType:
+				err = s.runLoop(ctx, exec, parsed, steps[i])
+			}
+			if err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		// blocks between two directives are scheduled together
+		indices := make([]int, 0)
+		for ; i < len(steps) && steps[i].directive == nil; i++ {
+			indices = append(indices, steps[i].index)
+		}
+		if err := s.runSegment(ctx, exec, parsed, indices); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scroll) runIf(ctx context.Context, exec *execution, parsed parsedScroll, st step) error {
+	ok, err := st.directive.condition.eval(exec.outputs)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return s.runSteps(ctx, exec, parsed, st.then)
+	}
+	return s.runSteps(ctx, exec, parsed, st.otherwise)
+}
+
+func (s *Scroll) runLoop(ctx context.Context, exec *execution, parsed parsedScroll, st step) error {
+	loop := st.directive.loop
+	exec.loops++
+	defer func() { exec.loops-- }()
+
+	for range loop.MaxIterations {
+		if err := s.runSteps(ctx, exec, parsed, st.then); err != nil {
+			return err
+		}
+		if loop.Until == nil {
+			continue
+		}
+		done, err := loop.Until.eval(exec.outputs)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return nil
+}
+
+// setOutput records the raw and parsed outputs of a gen. Outputs generated inside loops are accumulated
+// into lists with one output per iteration, conditions still test the raw output of the last one.
+func (exec *execution) setOutput(name string, resp string, value any) {
+	exec.outputs[name] = resp
+	if exec.loops == 0 {
+		delete(exec.lists, name)
+		exec.values[name] = value
+		return
+	}
+
+	if _, ok := exec.lists[name]; !ok {
+		exec.lists[name] = []string{resp}
+		exec.values[name] = []any{value}
+		return
+	}
+	exec.lists[name] = append(exec.lists[name], resp)
+	exec.values[name] = append(exec.values[name].([]any), value)
+}
+
+// genOutputs returns the raw outputs of the gens, the outputs accumulated inside loops are returned
+// as a JSON array of strings.
+func (exec *execution) genOutputs() map[string]string {
+	outputs := maps.Clone(exec.outputs)
+	for name, list := range exec.lists {
+		// a list of strings always marshals
+		data, _ := json.Marshal(list)
+		outputs[name] = string(data)
+	}
+	return outputs
+}
+
+// eventCandidates returns the candidates to report in gen finished events, they are left out when there was a single choice.
+func eventCandidates(candidates []Candidate) []Candidate {
+	if len(candidates) < 2 {
+		return nil
+	}
+	return candidates
+}
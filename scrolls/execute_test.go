@@ -0,0 +1,193 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// barrierLLM only answers once n requests are in flight, it answers each request with its last message content.
+type barrierLLM struct {
+	n       int
+	mu      sync.Mutex
+	arrived int
+	ready   chan struct{}
+}
+
+func (l *barrierLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	l.mu.Lock()
+	l.arrived++
+	if l.arrived == l.n {
+		close(l.ready)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.ready:
+	case <-time.After(5 * time.Second):
+		return openai.ChatCompletionObject{}, fmt.Errorf("gens were not prompted concurrently")
+	}
+
+	content := fmt.Sprintf("title %d", len(messages))
+	return openai.ChatCompletionObject{
+		Choices: []openai.Choice{{Message: openai.ChatCompletionMessage{Role: openai.AssistantRoleType, Content: &content}}},
+	}, nil
+}
+
+var testParallelTemplate string = `
+[[#user~]]
+Give me a title for {{.topic}}.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "title_1"}
+[[~/assistant]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "title_2", "parallel": true}
+[[~/assistant]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "title_3", "parallel": true}
+[[~/assistant]]
+`
+
+func TestScroll_ExecuteParallel(t *testing.T) {
+	ctx := context.Background()
+
+	llm := &barrierLLM{n: 3, ready: make(chan struct{})}
+	scroll := New(testParallelTemplate, llm, WithConcurrency(3))
+
+	msgs, outputs, err := scroll.Execute(ctx, map[string]any{"topic": "a blog post"})
+	require.NoError(t, err)
+
+	// every gen of the group sees the same one message history
+	assert.Equal(t, map[string]string{
+		"title_1": "title 1",
+		"title_2": "title 1",
+		"title_3": "title 1",
+	}, outputs)
+	require.Len(t, msgs, 4)
+	for _, msg := range msgs[1:] {
+		assert.Equal(t, openai.AssistantRoleType, msg.Role)
+	}
+}
+
+func TestScroll_ExecuteSequential(t *testing.T) {
+	ctx := context.Background()
+
+	template := `
+[[#user~]]
+Write a draft.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "draft"}
+[[~/assistant]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "final"}
+[[~/assistant]]
+`
+	llm := &scriptedLLM{responses: []string{"draft", "final"}}
+	scroll := New(template, llm)
+
+	_, outputs, err := scroll.Execute(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"draft": "draft", "final": "final"}, outputs)
+
+	// gens that are not parallel see the outputs of the gens before them
+	require.Len(t, llm.requests, 2)
+	assert.Len(t, llm.requests[0], 1)
+	assert.Len(t, llm.requests[1], 2)
+}
+
+func TestScroll_ExecuteOutputReference(t *testing.T) {
+	ctx := context.Background()
+
+	template := `
+[[#user~]]
+Give me a title.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "title"}
+[[~/assistant]]
+
+[[#user~]]
+Summarize the post titled {{output "title"}}.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "summary", "parallel": true}
+[[~/assistant]]
+`
+	// the scripted answers are in order, the summary can only be prompted once the title is generated
+	llm := &scriptedLLM{responses: []string{"Gophers", "A post about gophers."}}
+	scroll := New(template, llm, WithConcurrency(2))
+	require.NoError(t, scroll.Validate(nil))
+
+	msgs, outputs, err := scroll.Execute(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"title": "Gophers", "summary": "A post about gophers."}, outputs)
+
+	// the parallel gen still leaves the title out of its history, only the reference is resolved
+	require.Len(t, llm.requests, 2)
+	require.Len(t, llm.requests[1], 2)
+	assert.Equal(t, "Summarize the post titled Gophers.", *llm.requests[1][1].Content)
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "Summarize the post titled Gophers.", *msgs[2].Content)
+}
+
+func TestScroll_ExecuteOutputReferenceErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{
+			name: "Forward",
+			template: `
+[[#user~]]
+Improve {{output "draft"}}.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "draft"}
+[[~/assistant]]
+`,
+		},
+		{
+			name: "Unknown",
+			template: `
+[[#user~]]
+Improve {{output "draft"}}.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "final"}
+[[~/assistant]]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &scriptedLLM{responses: []string{"answer"}}
+			scroll := New(tt.template, llm)
+
+			_, _, err := scroll.Execute(ctx, nil)
+			assert.ErrorContains(t, err, `references output draft before it is generated`)
+			assert.Empty(t, llm.requests)
+		})
+	}
+
+	scroll := New(tests[1].template, &scriptedLLM{})
+	assert.ErrorContains(t, scroll.Validate(nil), "reference to unknown output draft")
+}
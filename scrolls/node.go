@@ -0,0 +1,119 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/dskart/gollum/ringchain"
+	"go.uber.org/zap"
+)
+
+const DefaultTranscriptKey = "transcript"
+
+// Node adapts a scroll into a ringchain.Node. It runs the scroll with the node args and
+// returns the parsed gen outputs along with the transcript of the execution.
+type Node struct {
+	name   string
+	scroll *Scroll
+
+	requiredInputs []string
+	inputMapping   map[string]string
+	transcriptKey  string
+	passthrough    bool
+}
+
+var _ ringchain.Node = (*Node)(nil)
+
+type NodeOptions struct {
+	requiredInputs []string
+	inputMapping   map[string]string
+	transcriptKey  string
+	passthrough    bool
+}
+
+// WithRequiredInputs makes the node fail if any of the given scroll args is missing.
+func WithRequiredInputs(keys ...string) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.requiredInputs = append(opts.requiredInputs, keys...)
+	}
+}
+
+// WithInputMapping renames node args before they are passed to the scroll, mapping is keyed by node arg name.
+func WithInputMapping(mapping map[string]string) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		if opts.inputMapping == nil {
+			opts.inputMapping = make(map[string]string, len(mapping))
+		}
+		maps.Copy(opts.inputMapping, mapping)
+	}
+}
+
+// WithTranscriptKey sets the result key of the transcript, it defaults to DefaultTranscriptKey.
+func WithTranscriptKey(key string) func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.transcriptKey = key
+	}
+}
+
+// WithPassthrough copies the node args into the node results so successors can use them too.
+func WithPassthrough() func(*NodeOptions) {
+	return func(opts *NodeOptions) {
+		opts.passthrough = true
+	}
+}
+
+func NewNode(name string, scroll *Scroll, opts ...func(*NodeOptions)) *Node {
+	options := NodeOptions{
+		transcriptKey: DefaultTranscriptKey,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Node{
+		name:           name,
+		scroll:         scroll,
+		requiredInputs: options.requiredInputs,
+		inputMapping:   options.inputMapping,
+		transcriptKey:  options.transcriptKey,
+		passthrough:    options.passthrough,
+	}
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+func (n *Node) Run(ctx context.Context, logger *zap.Logger, args map[string]any) (map[string]any, error) {
+	scrollArgs := maps.Clone(args)
+	if scrollArgs == nil {
+		scrollArgs = make(map[string]any)
+	}
+	for from, to := range n.inputMapping {
+		if v, ok := args[from]; ok {
+			delete(scrollArgs, from)
+			scrollArgs[to] = v
+		}
+	}
+
+	for _, key := range n.requiredInputs {
+		if _, ok := scrollArgs[key]; !ok {
+			return nil, fmt.Errorf("scroll node %s: missing required input %q", n.name, key)
+		}
+	}
+
+	logger.Debug("executing scroll", zap.String("node", n.name))
+	msgs, outputs, err := n.scroll.ExecuteParsed(ctx, scrollArgs)
+	if err != nil {
+		return nil, fmt.Errorf("scroll node %s: %w", n.name, err)
+	}
+
+	results := make(map[string]any, len(outputs)+1)
+	if n.passthrough {
+		maps.Copy(results, args)
+	}
+	maps.Copy(results, outputs)
+	results[n.transcriptKey] = msgs
+	return results, nil
+}
@@ -0,0 +1,45 @@
+package scrolls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/ringchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNode(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+
+	llm := &scriptedLLM{responses: []string{"42"}}
+	node := NewNode("answer", New(testTemplate, llm),
+		WithRequiredInputs("query"),
+		WithInputMapping(map[string]string{"question": "query"}),
+		WithPassthrough(),
+	)
+
+	g := ringchain.NewGraph()
+	require.NoError(t, g.AddNode(node.Name(), node))
+
+	res, err := g.Execute(ctx, logger, map[string]any{"question": "What is the meaning of life?"})
+	require.NoError(t, err)
+
+	nodeRes := res["answer"]
+	assert.Equal(t, "42", nodeRes["response"])
+	assert.Equal(t, "What is the meaning of life?", nodeRes["question"])
+	transcript, ok := nodeRes[DefaultTranscriptKey].([]openai.Message)
+	require.True(t, ok)
+	assert.Len(t, transcript, 3)
+
+	t.Run("MissingRequiredInput", func(t *testing.T) {
+		node := NewNode("answer", New(testTemplate, &scriptedLLM{}), WithRequiredInputs("query"))
+
+		_, err := node.Run(ctx, logger, map[string]any{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `missing required input "query"`)
+	})
+}
@@ -0,0 +1,180 @@
+package scrolls
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type ParserType string
+
+const (
+	JsonParserType       ParserType = "json"
+	JsonSchemaParserType ParserType = "json_schema"
+	ListParserType       ParserType = "list"
+	NumberParserType     ParserType = "number"
+	BooleanParserType    ParserType = "boolean"
+	RegexParserType      ParserType = "regex"
+)
+
+const defaultParserMaxRetries = 1
+
+// Parser turns the raw text of a gen output into a typed value.
+// If parsing fails, the scroll re-asks the model up to MaxRetries times with the parse error.
+type Parser struct {
+	Type ParserType `json:"type"`
+	// Schema is only valid for json_schema parsers
+	Schema *Schema `json:"schema,omitempty"`
+	// Separator is only valid for list parsers, it defaults to a new line
+	Separator *string `json:"separator,omitempty"`
+	// Pattern is only valid for regex parsers
+	Pattern    *string `json:"pattern,omitempty"`
+	MaxRetries *int    `json:"max_retries,omitempty"`
+}
+
+func (p Parser) maxRetries() int {
+	if p.MaxRetries == nil {
+		return defaultParserMaxRetries
+	}
+	return *p.MaxRetries
+}
+
+// Validate checks that the parser is well formed before any generation happens.
+func (p Parser) Validate() error {
+	switch p.Type {
+	case JsonParserType, ListParserType, NumberParserType, BooleanParserType:
+		return nil
+	case JsonSchemaParserType:
+		if p.Schema == nil {
+			return fmt.Errorf("json_schema parser requires a schema")
+		}
+		return nil
+	case RegexParserType:
+		if p.Pattern == nil {
+			return fmt.Errorf("regex parser requires a pattern")
+		}
+		if _, err := regexp.Compile(*p.Pattern); err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown parser type %q", p.Type)
+	}
+}
+
+func (p Parser) Parse(text string) (any, error) {
+	switch p.Type {
+	case JsonParserType:
+		return parseJson(text)
+	case JsonSchemaParserType:
+		v, err := parseJson(text)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Schema.Validate(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case ListParserType:
+		return parseList(text, p.Separator), nil
+	case NumberParserType:
+		return parseNumber(text)
+	case BooleanParserType:
+		return parseBoolean(text)
+	case RegexParserType:
+		return parseRegex(text, *p.Pattern)
+	default:
+		return nil, fmt.Errorf("unknown parser type %q", p.Type)
+	}
+}
+
+var codeFenceRe = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\n(.*?)\n?```$")
+
+func trimCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if match := codeFenceRe.FindStringSubmatch(text); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return text
+}
+
+func parseJson(text string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(trimCodeFence(text)), &v); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	return v, nil
+}
+
+var listItemPrefixRe = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+
+func parseList(text string, separator *string) []any {
+	sep := "\n"
+	if separator != nil {
+		sep = *separator
+	}
+
+	items := make([]any, 0)
+	for _, item := range strings.Split(trimCodeFence(text), sep) {
+		item = strings.TrimSpace(item)
+		item = listItemPrefixRe.ReplaceAllString(item, "")
+		if item == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func parseNumber(text string) (float64, error) {
+	text = strings.TrimSuffix(strings.TrimSpace(text), ".")
+	n, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return n, nil
+}
+
+func parseBoolean(text string) (bool, error) {
+	text = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(text), "."))
+	switch text {
+	case "true", "yes", "y":
+		return true, nil
+	case "false", "no", "n":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean %q, expected true or false", text)
+	}
+}
+
+// parseRegex returns the named groups of the first match as a map if the pattern has any,
+// the first group if the pattern has a single unnamed group, or the whole match otherwise.
+func parseRegex(text string, pattern string) (any, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("response does not match pattern %q", pattern)
+	}
+
+	names := re.SubexpNames()
+	groups := make(map[string]any)
+	for i, name := range names {
+		if i > 0 && name != "" {
+			groups[name] = match[i]
+		}
+	}
+	if len(groups) > 0 {
+		return groups, nil
+	}
+
+	if len(match) == 2 {
+		return match[1], nil
+	}
+	return match[0], nil
+}
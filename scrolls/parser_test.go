@@ -0,0 +1,132 @@
+package scrolls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_Parse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parser   Parser
+		text     string
+		expected any
+		wantErr  bool
+	}{
+		{name: "Json", parser: Parser{Type: JsonParserType}, text: "```json\n{\"a\": 1}\n```", expected: map[string]any{"a": 1.0}},
+		{name: "JsonInvalid", parser: Parser{Type: JsonParserType}, text: "not json", wantErr: true},
+		{
+			name: "JsonSchema",
+			parser: Parser{Type: JsonSchemaParserType, Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"score": {Type: "integer"}},
+				Required:   []string{"score"},
+			}},
+			text:     `{"score": 3}`,
+			expected: map[string]any{"score": 3.0},
+		},
+		{
+			name: "JsonSchemaMissingRequired",
+			parser: Parser{Type: JsonSchemaParserType, Schema: &Schema{
+				Type:     "object",
+				Required: []string{"score"},
+			}},
+			text:    `{}`,
+			wantErr: true,
+		},
+		{
+			name: "JsonSchemaEnum",
+			parser: Parser{Type: JsonSchemaParserType, Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"point": {Enum: []any{map[string]any{"x": 1.0}, map[string]any{"x": 2.0}}},
+					"tags":  {Enum: []any{[]any{"a", "b"}, "none"}},
+				},
+			}},
+			text:     `{"point": {"x": 2}, "tags": ["a", "b"]}`,
+			expected: map[string]any{"point": map[string]any{"x": 2.0}, "tags": []any{"a", "b"}},
+		},
+		{
+			name: "JsonSchemaEnumMismatch",
+			parser: Parser{Type: JsonSchemaParserType, Schema: &Schema{
+				Enum: []any{map[string]any{"x": 1.0}, []any{"a"}},
+			}},
+			text:    `{"x": 3}`,
+			wantErr: true,
+		},
+		{name: "List", parser: Parser{Type: ListParserType}, text: "- foo\n2. bar\n\n* baz", expected: []any{"foo", "bar", "baz"}},
+		{name: "ListSeparator", parser: Parser{Type: ListParserType, Separator: toPointer(",")}, text: "foo, bar", expected: []any{"foo", "bar"}},
+		{name: "Number", parser: Parser{Type: NumberParserType}, text: " 1,024.5\n", expected: 1024.5},
+		{name: "NumberInvalid", parser: Parser{Type: NumberParserType}, text: "about 3", wantErr: true},
+		{name: "Boolean", parser: Parser{Type: BooleanParserType}, text: "Yes.", expected: true},
+		{name: "RegexGroup", parser: Parser{Type: RegexParserType, Pattern: toPointer(`Answer: (\w+)`)}, text: "Answer: foo", expected: "foo"},
+		{name: "RegexNamedGroups", parser: Parser{Type: RegexParserType, Pattern: toPointer(`(?P<a>\d+)-(?P<b>\d+)`)}, text: "1-2", expected: map[string]any{"a": "1", "b": "2"}},
+		{name: "RegexNoMatch", parser: Parser{Type: RegexParserType, Pattern: toPointer(`\d+`)}, text: "foo", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.parser.Validate())
+			value, err := tc.parser.Parse(tc.text)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+var testParserTemplate string = `
+[[#user~]]
+Rate this movie from 1 to 5: {{.movie}}
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "rating", "parser": {"type": "number", "max_retries": 1}}
+[[~/assistant]]
+
+[[#user~]]
+List the main actors.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "actors", "parser": {"type": "list"}}
+[[~/assistant]]
+`
+
+func TestScroll_ExecuteInto(t *testing.T) {
+	ctx := context.Background()
+
+	llm := &scriptedLLM{responses: []string{"four stars", "4", "- Keanu Reeves\n- Carrie-Anne Moss"}}
+	scroll := New(testParserTemplate, llm)
+
+	var out struct {
+		Rating float64  `json:"rating"`
+		Actors []string `json:"actors"`
+	}
+	msgs, err := scroll.ExecuteInto(ctx, map[string]any{"movie": "The Matrix"}, &out)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4.0, out.Rating)
+	assert.Equal(t, []string{"Keanu Reeves", "Carrie-Anne Moss"}, out.Actors)
+
+	// the failed attempt is re-asked but does not end up in the transcript
+	require.Len(t, llm.requests, 3)
+	assert.Len(t, llm.requests[1], 3)
+	assert.Equal(t, "4", *msgs[1].Content)
+	assert.Len(t, msgs, 4)
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		llm := &scriptedLLM{responses: []string{"four stars", "four"}}
+		scroll := New(testParserTemplate, llm)
+
+		_, _, err := scroll.ExecuteParsed(ctx, map[string]any{"movie": "The Matrix"})
+		require.Error(t, err)
+		assert.Len(t, llm.requests, 2)
+	})
+}
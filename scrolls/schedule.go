@@ -0,0 +1,269 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/dskart/gollum/openai"
+	"golang.org/x/sync/errgroup"
+)
+
+// outputRefRe matches the references to gen outputs left in the blocks by the output template function.
+var outputRefRe = regexp.MustCompile(`\[\[output:([^\]]+)\]\]`)
+
+// outputFuncs are the template functions to reference gen outputs from the blocks after them:
+//
+//	{{output "draft"}}
+//
+// is replaced by the output of the draft gen action once it is generated.
+func outputFuncs() template.FuncMap {
+	return template.FuncMap{
+		"output": func(name string) string {
+			return "[[output:" + name + "]]"
+		},
+	}
+}
+
+// outputRefs returns the names of the outputs referenced by the message.
+func outputRefs(msg openai.Message) []string {
+	if msg.Content == nil {
+		return nil
+	}
+	refs := make([]string, 0)
+	for _, match := range outputRefRe.FindAllStringSubmatch(*msg.Content, -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// segmentBlock is a block of a segment, the blocks between two directives.
+type segmentBlock struct {
+	index int
+	msg   openai.Message
+	// gen is set for gen actions
+	gen *AssistantBody
+	// history are the positions in the segment of the blocks in the history of a gen
+	history []int
+	// refs are the positions of the gens producing the referenced outputs, -1 for outputs generated before the segment
+	refs map[string]int
+	// deps are the positions of the gens that must be generated before the block runs
+	deps []int
+}
+
+// analyzeSegment finds what every block of the segment depends on. A gen depends on the gens in its history, which
+// is every block before it except the gens it runs in parallel with, and on the outputs referenced by its history.
+// Other blocks only depend on the outputs they reference.
+func analyzeSegment(blocks []openai.Message, indices []int, defaults AssistantBody, generated map[string]string) ([]segmentBlock, error) {
+	segment := make([]segmentBlock, len(indices))
+	producers := make(map[string]int)
+	for p, index := range indices {
+		block := segmentBlock{index: index, msg: blocks[index], refs: make(map[string]int)}
+
+		body, ok := parseGenAction(block.msg)
+		if !ok {
+			for _, name := range outputRefs(block.msg) {
+				if q, ok := producers[name]; ok {
+					block.refs[name] = q
+					block.deps = append(block.deps, q)
+				} else if _, ok := generated[name]; ok {
+					block.refs[name] = -1
+				} else {
+					return nil, fmt.Errorf("block %d references output %s before it is generated", index, name)
+				}
+			}
+			segment[p] = block
+			continue
+		}
+
+		body = body.WithDefaults(defaults)
+		block.gen = &body
+
+		// a parallel gen leaves out of its history the gens before it up to the first gen that is not parallel
+		siblings := make(map[int]bool)
+		if body.Parallel {
+			for q := p - 1; q >= 0; q-- {
+				if segment[q].gen == nil {
+					continue
+				}
+				siblings[q] = true
+				if !segment[q].gen.Parallel {
+					break
+				}
+			}
+		}
+		for q := range p {
+			if siblings[q] {
+				continue
+			}
+			block.history = append(block.history, q)
+			if segment[q].gen != nil {
+				block.deps = append(block.deps, q)
+			} else {
+				block.deps = append(block.deps, segment[q].deps...)
+			}
+		}
+		slices.Sort(block.deps)
+		block.deps = slices.Compact(block.deps)
+
+		producers[body.OutputName] = p
+		segment[p] = block
+	}
+	return segment, nil
+}
+
+// runSegment runs the blocks between two directives. Blocks are started in order once the gens they depend on are
+// generated, so independent gens are prompted concurrently. The history is still built in block order.
+func (s *Scroll) runSegment(ctx context.Context, exec *execution, parsed parsedScroll, indices []int) error {
+	segment, err := analyzeSegment(parsed.msgs, indices, parsed.defaults, exec.outputs)
+	if err != nil {
+		return err
+	}
+	for _, block := range segment {
+		if block.gen == nil {
+			continue
+		}
+		if block.gen.Parser != nil {
+			if err := block.gen.Parser.Validate(); err != nil {
+				return fmt.Errorf("invalid parser for %s: %w", block.gen.OutputName, err)
+			}
+		}
+		if block.gen.Aggregate != nil {
+			if err := s.validateAggregate(*block.gen.Aggregate); err != nil {
+				return fmt.Errorf("invalid aggregate for %s: %w", block.gen.OutputName, err)
+			}
+		}
+	}
+
+	type completion struct {
+		position int
+		res      genResult
+		err      error
+	}
+	completions := make(chan completion, len(segment))
+	results := make([]genResult, len(segment))
+	resolved := make([]openai.Message, len(segment))
+	done := make([]bool, len(segment))
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	if s.concurrency > 0 {
+		eg.SetLimit(s.concurrency)
+	}
+	wait := func(deps []int) error {
+		for slices.ContainsFunc(deps, func(q int) bool { return !done[q] }) {
+			c := <-completions
+			if c.err != nil {
+				return c.err
+			}
+			results[c.position] = c.res
+			done[c.position] = true
+		}
+		return nil
+	}
+	fail := func(err error) error {
+		// the other gens are canceled by the errgroup
+		_ = eg.Wait()
+		return err
+	}
+
+	gens := make([]int, 0)
+	for p, block := range segment {
+		if err := wait(block.deps); err != nil {
+			return fail(err)
+		}
+
+		if block.gen == nil {
+			resolved[p] = resolveOutputRefs(block, results, exec.outputs)
+			done[p] = true
+			exec.events.emit(Event{Type: BlockRenderedEventType, Index: block.index, Message: &resolved[p]})
+			continue
+		}
+
+		history := slices.Clone(exec.msgs)
+		for _, q := range block.history {
+			if segment[q].gen != nil {
+				history = append(history, openai.Message{Role: openai.AssistantRoleType, Content: &results[q].resp})
+			} else {
+				history = append(history, resolved[q])
+			}
+		}
+
+		gens = append(gens, p)
+		eg.Go(func() error {
+			res, err := s.runGen(egCtx, exec, block.index, *block.gen, history)
+			completions <- completion{position: p, res: res, err: err}
+			return err
+		})
+	}
+	if err := wait(gens); err != nil {
+		return fail(err)
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// results are recorded in block order so the history stays deterministic
+	for p, block := range segment {
+		if block.gen == nil {
+			exec.msgs = append(exec.msgs, resolved[p])
+			continue
+		}
+		res := results[p]
+		exec.usage = addUsage(exec.usage, res.usage)
+		exec.setOutput(block.gen.OutputName, res.resp, res.value)
+		exec.candidates[block.gen.OutputName] = res.candidates
+		exec.msgs = append(exec.msgs, openai.Message{
+			Role:    openai.AssistantRoleType,
+			Content: &res.resp,
+		})
+	}
+	return nil
+}
+
+func (s *Scroll) runGen(ctx context.Context, exec *execution, index int, body AssistantBody, history []openai.Message) (genResult, error) {
+	name := body.OutputName
+	exec.events.emit(Event{Type: GenStartedEventType, Index: index, OutputName: name})
+
+	var onDelta func(string)
+	if exec.events.enabled() {
+		onDelta = func(delta string) {
+			exec.events.emit(Event{Type: TokenDeltaEventType, Index: index, OutputName: name, Delta: delta})
+		}
+	}
+
+	start := time.Now()
+	res, err := body.gen(ctx, s.openAi, history, onDelta, s.judges)
+	if err != nil {
+		return res, err
+	}
+	exec.events.emit(Event{
+		Type:       GenFinishedEventType,
+		Index:      index,
+		OutputName: name,
+		Output:     res.resp,
+		Usage:      &res.usage,
+		Latency:    time.Since(start),
+		Candidates: eventCandidates(res.candidates),
+	})
+	return res, nil
+}
+
+// resolveOutputRefs replaces the output references of the block with the outputs.
+func resolveOutputRefs(block segmentBlock, results []genResult, generated map[string]string) openai.Message {
+	msg := block.msg
+	if len(block.refs) == 0 {
+		return msg
+	}
+	content := outputRefRe.ReplaceAllStringFunc(*msg.Content, func(ref string) string {
+		name := outputRefRe.FindStringSubmatch(ref)[1]
+		if q := block.refs[name]; q >= 0 {
+			return results[q].resp
+		}
+		return generated[name]
+	})
+	msg.Content = &content
+	return msg
+}
@@ -0,0 +1,95 @@
+package scrolls
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// Schema is the subset of JSON Schema supported by the json_schema parser.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+func (s *Schema) Validate(v any) error {
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if s == nil {
+		return nil
+	}
+
+	// enums can hold objects and arrays, which can't be compared with ==
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, v, s.Enum)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, key)
+			}
+		}
+		for key, value := range obj {
+			propSchema, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, key)
+				}
+				continue
+			}
+			if err := propSchema.validate(path+"."+key, value); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "null":
+		if v != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"maps"
 	"regexp"
 	"strings"
 	"sync"
@@ -17,13 +16,34 @@ import (
 type Scroll struct {
 	openAi openai.OpenAi
 
-	text    string
-	funcMap template.FuncMap
+	text        string
+	base        *string
+	partials    map[string]string
+	funcMap     template.FuncMap
+	concurrency int
+
+	tokenizer          Tokenizer
+	truncationPolicies map[string]TruncationPolicy
+	tokenBudget        *tokenBudget
+	exampleStore       ExampleStore
+	judges             map[string]*Scroll
+
 	mu      sync.RWMutex
+	tmpl    *template.Template
+	tmplErr error
 }
 
 type Options struct {
-	funcMap template.FuncMap
+	funcMap     template.FuncMap
+	concurrency int
+	partials    map[string]string
+	base        *string
+
+	tokenizer          Tokenizer
+	truncationPolicies map[string]TruncationPolicy
+	tokenBudget        *tokenBudget
+	exampleStore       ExampleStore
+	judges             map[string]*Scroll
 }
 
 func WithFuncMap(funcMap template.FuncMap) func(*Options) {
@@ -32,93 +52,329 @@ func WithFuncMap(funcMap template.FuncMap) func(*Options) {
 	}
 }
 
+// WithConcurrency sets the maximum number of parallel gen actions that can be prompted at the same time.
+func WithConcurrency(n int) func(*Options) {
+	return func(opts *Options) {
+		opts.concurrency = n
+	}
+}
+
 func New(text string, openAi openai.OpenAi, opts ...func(*Options)) *Scroll {
-	options := Options{}
+	options := Options{
+		concurrency: 4,
+		tokenizer:   DefaultTokenizer,
+	}
 	for _, option := range opts {
 		option(&options)
 	}
 
 	return &Scroll{
-		text:    text,
-		openAi:  openAi,
-		funcMap: options.funcMap,
+		text:        text,
+		openAi:      openAi,
+		base:        options.base,
+		partials:    options.partials,
+		funcMap:     options.funcMap,
+		concurrency: options.concurrency,
+
+		tokenizer:          options.tokenizer,
+		truncationPolicies: options.truncationPolicies,
+		tokenBudget:        options.tokenBudget,
+		exampleStore:       options.exampleStore,
+		judges:             options.judges,
 	}
 }
 
-var re = regexp.MustCompile(`(?s)\[\[#(system|user|assistant)~\]\](.*?)\[\[~/(system|user|assistant)\]\]`)
+var re = regexp.MustCompile(`(?s)\[\[#(system|user|assistant|tool)((?:\s+\w+="[^"]*")*)\s*~\]\](.*?)\[\[~/(system|user|assistant|tool)\]\]`)
+
+// toolCallRe matches the tool calls recorded inside an assistant block.
+var toolCallRe = regexp.MustCompile(`(?s)\[\[#tool_call((?:\s+\w+="[^"]*")*)\s*~\]\](.*?)\[\[~/tool_call\]\]`)
+
+var attributeRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// defaultsRe matches the scroll-level defaults block holding the prompt options shared by every gen action.
+var defaultsRe = regexp.MustCompile(`(?s)\[\[#defaults~\]\](.*?)\[\[~/defaults\]\]`)
 
+type parsedScroll struct {
+	defaults    AssistantBody
+	msgs        []openai.Message
+	directives  []directive
+	truncations []Truncation
+}
+
+// ParseBlocks renders the scroll and returns its messages, control flow directives are left out.
 func (s *Scroll) ParseBlocks(args map[string]any) ([]openai.Message, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	parsed, err := s.parse(context.Background(), args)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.msgs, nil
+}
 
-	tmpl, err := template.New("scroll").Funcs(s.funcMap).Parse(s.text)
+func (s *Scroll) render(ctx context.Context, tmpl *template.Template, args map[string]any) (parsedScroll, error) {
+	tmpl, err := tmpl.Clone()
 	if err != nil {
-		return nil, fmt.Errorf("could not parse template text: %w", err)
+		return parsedScroll{}, fmt.Errorf("could not clone template: %w", err)
 	}
+	funcs, truncations := s.renderFuncs(ctx)
+	tmpl.Funcs(funcs)
 
 	buf := new(bytes.Buffer)
 	err = tmpl.Execute(buf, args)
 	if err != nil {
-		return nil, fmt.Errorf("could not execute template: %w", err)
+		return parsedScroll{}, fmt.Errorf("could not execute template: %w", err)
 	}
 
 	parsedText := buf.String()
-	matches := re.FindAllStringSubmatch(parsedText, -1)
+
+	parsed := parsedScroll{
+		truncations: truncations(),
+	}
+	defaultsMatches := defaultsRe.FindAllStringSubmatch(parsedText, -1)
+	if len(defaultsMatches) > 1 {
+		return parsedScroll{}, fmt.Errorf("found %d defaults blocks, expected at most one", len(defaultsMatches))
+	} else if len(defaultsMatches) == 1 {
+		if err := json.Unmarshal([]byte(defaultsMatches[0][1]), &parsed.defaults); err != nil {
+			return parsedScroll{}, fmt.Errorf("could not unmarshal defaults block: %w", err)
+		}
+	}
+
+	matches := re.FindAllStringSubmatchIndex(parsedText, -1)
 	if len(matches) < 1 {
-		return nil, fmt.Errorf("could not find any message container matches")
+		return parsedScroll{}, fmt.Errorf("could not find any message container matches")
 	}
 
-	msgs := make([]openai.Message, 0, len(matches))
+	parsed.msgs = make([]openai.Message, 0, len(matches))
 	for _, match := range matches {
-		role := match[1]    // The matched role (system, user, or assistant)
-		content := match[2] // The content between {{#...~}} and {{~/...}}
+		role := parsedText[match[2]:match[3]]       // The matched role (system, user, assistant or tool)
+		attributes := parsedText[match[4]:match[5]] // The attributes of the opening tag, e.g. name="alice"
+		content := parsedText[match[6]:match[7]]    // The content between {{#...~}} and {{~/...}}
 		content = strings.Trim(content, "\n")
 
-		msgs = append(msgs, openai.Message{
-			Role:    openai.RoleType(role),
-			Content: &content,
-		})
+		msg, err := parseMessage(openai.RoleType(role), parseAttributes(attributes), content)
+		if err != nil {
+			return parsedScroll{}, fmt.Errorf("invalid %s block: %w", role, err)
+		}
+		parsed.msgs = append(parsed.msgs, msg)
 	}
 
+	// directives are placed before the first message that starts after them
+	parsed.directives = make([]directive, 0)
+	msg := 0
+	for _, match := range directiveRe.FindAllStringSubmatchIndex(parsedText, -1) {
+		typ := parsedText[match[2]:match[3]]
+		if closing := parsedText[match[6]:match[7]]; closing != typ {
+			return parsedScroll{}, fmt.Errorf("%s directive closed by %s", typ, closing)
+		}
+		for msg < len(matches) && matches[msg][1] <= match[0] {
+			msg++
+		}
+		if msg < len(matches) && matches[msg][0] < match[0] {
+			return parsedScroll{}, fmt.Errorf("%s directive inside a message block", typ)
+		}
+
+		d, err := parseDirective(DirectiveType(typ), msg, parsedText[match[4]:match[5]])
+		if err != nil {
+			return parsedScroll{}, fmt.Errorf("invalid %s directive: %w", typ, err)
+		}
+		parsed.directives = append(parsed.directives, d)
+	}
+	if _, err := buildSteps(len(parsed.msgs), parsed.directives); err != nil {
+		return parsedScroll{}, err
+	}
+
+	return parsed, nil
+}
+
+func parseAttributes(text string) map[string]string {
+	attributes := make(map[string]string)
+	for _, match := range attributeRe.FindAllStringSubmatch(text, -1) {
+		attributes[match[1]] = match[2]
+	}
+	return attributes
+}
+
+func parseMessage(role openai.RoleType, attributes map[string]string, content string) (openai.Message, error) {
+	msg := openai.Message{
+		Role:    role,
+		Content: &content,
+	}
+
+	for key, value := range attributes {
+		switch {
+		case key == "name":
+			msg.Name = &value
+		case key == "id" && role == openai.ToolRoleType:
+			msg.ToolCallId = value
+		default:
+			return openai.Message{}, fmt.Errorf("unknown attribute %q", key)
+		}
+	}
+
+	if role == openai.ToolRoleType && msg.ToolCallId == "" {
+		return openai.Message{}, fmt.Errorf("tool blocks require an id attribute")
+	}
+
+	if role == openai.AssistantRoleType {
+		toolCallMatches := toolCallRe.FindAllStringSubmatch(content, -1)
+		for i, match := range toolCallMatches {
+			toolCallAttributes := parseAttributes(match[1])
+			toolCall := openai.ToolCall{
+				Id:   toolCallAttributes["id"],
+				Type: openai.FunctionToolType,
+				Function: openai.FunctionCall{
+					Name:      toolCallAttributes["name"],
+					Arguments: strings.TrimSpace(match[2]),
+				},
+			}
+			if toolCall.Id == "" || toolCall.Function.Name == "" {
+				return openai.Message{}, fmt.Errorf("tool call %d requires id and name attributes", i)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, toolCall)
+		}
+
+		if len(toolCallMatches) > 0 {
+			content = strings.TrimSpace(toolCallRe.ReplaceAllString(content, ""))
+			if content == "" {
+				msg.Content = nil
+			} else {
+				msg.Content = &content
+			}
+		}
+	}
+
+	return msg, nil
+}
+
+// Execute executes the scroll template and returns all the execute openai.Messages along with the raw gen outputs.
+// The outputs of gens inside loops are returned as a JSON array of strings with one output per iteration.
+func (s *Scroll) Execute(ctx context.Context, args map[string]any, opts ...func(*ExecuteOptions)) ([]openai.Message, map[string]string, error) {
+	exec, err := s.execute(ctx, args, opts...)
+	if err != nil {
+		if exec == nil {
+			return nil, nil, err
+		}
+		return nil, exec.genOutputs(), err
+	}
+	return exec.msgs, exec.genOutputs(), nil
+}
+
+// ExecuteParsed executes the scroll like Execute but returns the gen outputs as parsed by their parser.
+// Gen outputs without a parser are returned as strings.
+func (s *Scroll) ExecuteParsed(ctx context.Context, args map[string]any, opts ...func(*ExecuteOptions)) ([]openai.Message, map[string]any, error) {
+	exec, err := s.execute(ctx, args, opts...)
+	if err != nil {
+		if exec == nil {
+			return nil, nil, err
+		}
+		return nil, exec.values, err
+	}
+	return exec.msgs, exec.values, nil
+}
+
+// ExecuteInto executes the scroll and decodes the parsed gen outputs into v, keyed by output name.
+// v must be a pointer to a struct or a map, struct fields are matched using their json tags.
+func (s *Scroll) ExecuteInto(ctx context.Context, args map[string]any, v any, opts ...func(*ExecuteOptions)) ([]openai.Message, error) {
+	msgs, values, err := s.ExecuteParsed(ctx, args, opts...)
+	if err != nil {
+		return msgs, err
+	}
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return msgs, fmt.Errorf("could not marshal gen outputs: %w", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return msgs, fmt.Errorf("could not decode gen outputs: %w", err)
+	}
 	return msgs, nil
 }
 
-// Execute executes the scroll template and returns all the execute openai.Messages
-func (s *Scroll) Execute(ctx context.Context, args map[string]any) ([]openai.Message, map[string]string, error) {
-	blocks, err := s.ParseBlocks(args)
+// Result holds everything produced by a scroll execution.
+type Result struct {
+	Messages []openai.Message
+	Outputs  map[string]string
+	Values   map[string]any
+	// Candidates holds every choice generated for each gen action, keyed by output name
+	Candidates  map[string][]Candidate
+	Usage       openai.Usage
+	Truncations []Truncation
+}
+
+// ExecuteResult executes the scroll like Execute but returns everything the execution produced.
+// On error, the messages are left out but the rest of the result holds what was generated until then.
+func (s *Scroll) ExecuteResult(ctx context.Context, args map[string]any, opts ...func(*ExecuteOptions)) (Result, error) {
+	exec, err := s.execute(ctx, args, opts...)
+	if exec == nil {
+		return Result{}, err
+	}
+
+	res := Result{
+		Outputs:     exec.genOutputs(),
+		Values:      exec.values,
+		Candidates:  exec.candidates,
+		Usage:       exec.usage,
+		Truncations: exec.truncations,
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Messages = exec.msgs
+	return res, nil
+}
+
+// Validate renders the scroll with args and checks its defaults block and gen actions without prompting the llm.
+func (s *Scroll) Validate(args map[string]any) error {
+	parsed, err := s.parse(context.Background(), args)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not parse scroll: %w", err)
+		return err
 	}
 
-	genArgs := maps.Clone(args)
-	genOutputs := make(map[string]string)
-	outputMsgs := make([]openai.Message, 0, len(blocks))
-	for _, msg := range blocks {
-		newHistoryMsg := openai.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
+	if parsed.defaults.Parser != nil {
+		return fmt.Errorf("defaults block: parsers can only be set on gen actions")
+	}
+
+	outputNames := make(map[string]bool)
+	for _, msg := range parsed.msgs {
+		if body, ok := parseGenAction(msg); ok {
+			outputNames[body.OutputName] = true
 		}
+	}
 
-		if msg.Role == "assistant" {
-			assistantAction := true
-			var assistantBody AssistantBody
-			if err := json.Unmarshal([]byte(*msg.Content), &assistantBody); err != nil {
-				// if we can't unmarshal the assistant body, then it's not an assistant action
-				assistantAction = false
+	for i, msg := range parsed.msgs {
+		for _, name := range outputRefs(msg) {
+			if !outputNames[name] {
+				return fmt.Errorf("block %d: reference to unknown output %s", i, name)
 			}
+		}
+		if msg.Role != openai.AssistantRoleType || msg.Content == nil {
+			continue
+		}
+		content := strings.TrimSpace(*msg.Content)
+		if !strings.HasPrefix(content, "{") || !strings.Contains(content, `"action"`) && !strings.Contains(content, `"output_name"`) {
+			continue
+		}
 
-			if assistantAction {
-				resp, err := promptOpenAi(ctx, s.openAi, outputMsgs, assistantBody.OpenAiPromptOptions()...)
-				if err != nil {
-					return nil, genOutputs, fmt.Errorf("failed to prompt openai: %w", err)
-				}
-				genArgs[assistantBody.OutputName] = resp
-				genOutputs[assistantBody.OutputName] = resp
-				newHistoryMsg.Content = &resp
+		var assistantBody AssistantBody
+		if err := json.Unmarshal([]byte(content), &assistantBody); err != nil {
+			return fmt.Errorf("block %d: invalid gen action: %w", i, err)
+		}
+		if assistantBody.Action != GenActionType && assistantBody.Action != "" {
+			return fmt.Errorf("block %d: unknown action %q", i, assistantBody.Action)
+		}
+		if assistantBody.OutputName == "" {
+			return fmt.Errorf("block %d: gen action without output_name", i)
+		}
+		if assistantBody.Parser != nil {
+			if err := assistantBody.Parser.Validate(); err != nil {
+				return fmt.Errorf("block %d: invalid parser for %s: %w", i, assistantBody.OutputName, err)
+			}
+		}
+		if assistantBody.Aggregate != nil {
+			if err := s.validateAggregate(*assistantBody.Aggregate); err != nil {
+				return fmt.Errorf("block %d: invalid aggregate for %s: %w", i, assistantBody.OutputName, err)
 			}
 		}
-		outputMsgs = append(outputMsgs, newHistoryMsg)
 	}
 
-	return outputMsgs, genOutputs, nil
+	return nil
 }
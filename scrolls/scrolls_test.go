@@ -3,9 +3,11 @@ package scrolls
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"text/template"
 	"time"
@@ -71,6 +73,37 @@ func toPointer[T any](t T) *T {
 	return &t
 }
 
+// scriptedLLM returns its responses in order and records every request it receives.
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []string
+	requests  [][]openai.Message
+}
+
+func (l *scriptedLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests = append(l.requests, messages)
+	if len(l.responses) == 0 {
+		return openai.ChatCompletionObject{}, fmt.Errorf("no scripted response left")
+	}
+	content := l.responses[0]
+	l.responses = l.responses[1:]
+
+	return openai.ChatCompletionObject{
+		Choices: []openai.Choice{
+			{
+				FinishReason: openai.StopFinishReasonType,
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.AssistantRoleType,
+					Content: &content,
+				},
+			},
+		},
+	}, nil
+}
+
 func TestScrolls(t *testing.T) {
 	ctx := context.Background()
 	content := "I'm Sorry Dave, I'm Afraid I Can't Do That"
@@ -191,3 +224,173 @@ func TestParseBlocks(t *testing.T) {
 	}
 	assert.Equal(t, expected, blocks)
 }
+
+var testDefaultsTemplate string = `
+[[#defaults~]]
+{"model": "gpt-4o-mini", "seed": 7, "temperature": 0.5}
+[[~/defaults]]
+
+[[#user~]]
+Answer in json: {{.query}}
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "response", "model": "gpt-4o", "temperature": 0, "response_format": {"type": "json_object"}}
+[[~/assistant]]
+`
+
+func TestScrolls_Defaults(t *testing.T) {
+	ctx := context.Background()
+	content := `{"answer": 42}`
+	resp := openai.ChatCompletionObject{
+		Choices: []openai.Choice{
+			{Message: openai.ChatCompletionMessage{Role: openai.AssistantRoleType, Content: &content}},
+		},
+	}
+	expectedBody := openai.ChatCompletionRequestBody{
+		Model: "gpt-4o",
+		Messages: []openai.Message{
+			{Role: openai.UserRoleType, Content: toPointer("Answer in json: What is the meaning of life?")},
+		},
+		ResponseFormat: &openai.ResponseFormat{Type: openai.JsonObjectResponseFormatType},
+		Seed:           toPointer(7),
+		Temperature:    toPointer(0.0),
+	}
+
+	svr := newTestServer(t, expectedBody, resp)
+	defer svr.Close()
+
+	openAi, err := openai.New(openai.Config{
+		OpenAiKey: TEST_KEY,
+		GptModel:  TEST_MODEL,
+	}, openai.WithRetryableHttpClient(testHttpClient()), openai.WithUrl(svr.URL))
+	require.NoError(t, err)
+
+	scroll := New(testDefaultsTemplate, openAi)
+
+	blocks, err := scroll.ParseBlocks(map[string]any{"query": "What is the meaning of life?"})
+	require.NoError(t, err)
+	assert.Len(t, blocks, 2)
+
+	_, outputs, err := scroll.Execute(ctx, map[string]any{"query": "What is the meaning of life?"})
+	require.NoError(t, err)
+	assert.Equal(t, content, outputs["response"])
+}
+
+var testToolTemplate string = `
+[[#system~]]
+You are a weather assistant.
+[[~/system]]
+
+[[#user name="alice"~]]
+What's the weather in Paris?
+[[~/user]]
+
+[[#assistant~]]
+[[#tool_call id="call_1" name="get_weather"~]]
+{"city": "Paris"}
+[[~/tool_call]]
+[[~/assistant]]
+
+[[#tool id="call_1"~]]
+{"temperature": 21}
+[[~/tool]]
+
+[[#assistant~]]
+{"temperature": 21, "unit": "celsius"}
+[[~/assistant]]
+
+[[#user name="bob"~]]
+And in {{.city}}?
+[[~/user]]
+`
+
+func TestParseBlocks_ToolMessages(t *testing.T) {
+	scroll := New(testToolTemplate, nil)
+
+	blocks, err := scroll.ParseBlocks(map[string]any{"city": "London"})
+	require.NoError(t, err)
+	expected := []openai.Message{
+		{Role: openai.SystemRoleType, Content: toPointer("You are a weather assistant.")},
+		{Role: openai.UserRoleType, Name: toPointer("alice"), Content: toPointer("What's the weather in Paris?")},
+		{
+			Role: openai.AssistantRoleType,
+			ToolCalls: []openai.ToolCall{
+				{Id: "call_1", Type: openai.FunctionToolType, Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city": "Paris"}`}},
+			},
+		},
+		{Role: openai.ToolRoleType, ToolCallId: "call_1", Content: toPointer(`{"temperature": 21}`)},
+		{Role: openai.AssistantRoleType, Content: toPointer(`{"temperature": 21, "unit": "celsius"}`)},
+		{Role: openai.UserRoleType, Name: toPointer("bob"), Content: toPointer("And in London?")},
+	}
+	assert.Equal(t, expected, blocks)
+
+	// json assistant messages that are not gen actions are sent as is
+	llm := &scriptedLLM{}
+	msgs, outputs, err := New(testToolTemplate, llm).Execute(context.Background(), map[string]any{"city": "London"})
+	require.NoError(t, err)
+	assert.Empty(t, llm.requests)
+	assert.Empty(t, outputs)
+	assert.Equal(t, expected, msgs)
+
+	t.Run("MissingToolCallId", func(t *testing.T) {
+		_, err := New(`[[#tool~]]21[[~/tool]]`, nil).ParseBlocks(nil)
+		require.Error(t, err)
+	})
+
+	t.Run("UnknownAttribute", func(t *testing.T) {
+		_, err := New(`[[#user role="alice"~]]hi[[~/user]]`, nil).ParseBlocks(nil)
+		require.Error(t, err)
+	})
+}
+
+func TestScroll_Validate(t *testing.T) {
+	require.NoError(t, New(testTemplate, nil).Validate(map[string]any{"query": "foo"}))
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{name: "InvalidJson", body: `{"action": "gen", "output_name": "response",}`},
+		{name: "UnknownAction", body: `{"action": "generate", "output_name": "response"}`},
+		{name: "MissingOutputName", body: `{"action": "gen"}`},
+		{name: "InvalidParser", body: `{"action": "gen", "output_name": "response", "parser": {"type": "regex"}}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scroll := New("[[#user~]]hi[[~/user]]\n[[#assistant~]]"+tc.body+"[[~/assistant]]", nil)
+			require.Error(t, scroll.Validate(nil))
+		})
+	}
+}
+
+func TestScrolls_ActionlessGen(t *testing.T) {
+	template := `
+[[#user~]]
+Give me a JSON example.
+[[~/user]]
+
+[[#assistant~]]
+{"city": "Paris"}
+[[~/assistant]]
+
+[[#user~]]
+Another one.
+[[~/user]]
+
+[[#assistant~]]
+{"type": "openai", "output_name": "response"}
+[[~/assistant]]
+`
+	llm := &scriptedLLM{responses: []string{`{"city": "Rome"}`}}
+	scroll := New(template, llm)
+	require.NoError(t, scroll.Validate(nil))
+
+	// the few-shot json is sent as is, the block with an output name is still generated
+	msgs, outputs, err := scroll.Execute(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"response": `{"city": "Rome"}`}, outputs)
+	require.Len(t, llm.requests, 1)
+	assert.Len(t, llm.requests[0], 3)
+	assert.Len(t, msgs, 4)
+}
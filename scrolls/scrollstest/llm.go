@@ -0,0 +1,100 @@
+package scrollstest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// Script is a scripted response of a ScriptedLLM. It answers the first request its When predicate matches,
+// a script without a predicate matches any request.
+type Script struct {
+	When     func(messages []openai.Message) bool
+	Response string
+}
+
+// LastMessageContains matches the requests whose last message contains substr.
+func LastMessageContains(substr string) func(messages []openai.Message) bool {
+	return func(messages []openai.Message) bool {
+		if len(messages) == 0 {
+			return false
+		}
+		content := messages[len(messages)-1].Content
+		return content != nil && strings.Contains(*content, substr)
+	}
+}
+
+// ScriptedLLM is a fake openai.OpenAi that answers every request with the first unused script matching it.
+// It records every request so tests can assert on the prompts that were sent.
+//
+// Scripts without a predicate are used in the order requests arrive. Parallel gens are prompted concurrently so
+// their arrival order isn't deterministic, give their scripts a predicate with NewMatchingLLM instead.
+type ScriptedLLM struct {
+	mu       sync.Mutex
+	scripts  []Script
+	requests [][]openai.Message
+}
+
+var _ openai.OpenAi = (*ScriptedLLM)(nil)
+
+// NewScriptedLLM returns a ScriptedLLM answering the requests with the responses in order.
+func NewScriptedLLM(responses ...string) *ScriptedLLM {
+	scripts := make([]Script, 0, len(responses))
+	for _, response := range responses {
+		scripts = append(scripts, Script{Response: response})
+	}
+	return NewMatchingLLM(scripts...)
+}
+
+// NewMatchingLLM returns a ScriptedLLM answering the requests with the scripts they match.
+func NewMatchingLLM(scripts ...Script) *ScriptedLLM {
+	return &ScriptedLLM{
+		scripts: scripts,
+	}
+}
+
+func (l *ScriptedLLM) ChatCompletionCreate(ctx context.Context, messages []openai.Message, opts ...func(*openai.ChatCompletionOptions)) (openai.ChatCompletionObject, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests = append(l.requests, slices.Clone(messages))
+	i := slices.IndexFunc(l.scripts, func(script Script) bool {
+		return script.When == nil || script.When(messages)
+	})
+	if i < 0 {
+		return openai.ChatCompletionObject{}, fmt.Errorf("scripted llm: no script left for request %d", len(l.requests))
+	}
+	content := l.scripts[i].Response
+	l.scripts = slices.Delete(l.scripts, i, i+1)
+
+	return openai.ChatCompletionObject{
+		Object: "chat.completion",
+		Choices: []openai.Choice{
+			{
+				FinishReason: openai.StopFinishReasonType,
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.AssistantRoleType,
+					Content: &content,
+				},
+			},
+		},
+	}, nil
+}
+
+// Requests returns the messages of every request received so far.
+func (l *ScriptedLLM) Requests() [][]openai.Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.requests)
+}
+
+// Remaining returns the number of scripts that have not been used yet.
+func (l *ScriptedLLM) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.scripts)
+}
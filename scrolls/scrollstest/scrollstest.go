@@ -0,0 +1,67 @@
+// Package scrollstest provides helpers to test scrolls offline against golden files.
+//
+// Run the tests with -scrollstest.update to rewrite the golden files with the current output.
+package scrollstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/dskart/gollum/scrolls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("scrollstest.update", false, "update the scroll golden files")
+
+// Transcript is the snapshot of a scroll execution stored in golden files.
+type Transcript struct {
+	Messages []openai.Message `json:"messages"`
+	Outputs  map[string]any   `json:"outputs"`
+}
+
+// AssertMessagesGolden renders the scroll with args and compares the resulting messages with the golden file.
+func AssertMessagesGolden(t testing.TB, scroll *scrolls.Scroll, args map[string]any, goldenPath string) {
+	t.Helper()
+
+	msgs, err := scroll.ParseBlocks(args)
+	require.NoError(t, err)
+	assertGolden(t, msgs, goldenPath)
+}
+
+// AssertTranscriptGolden executes the scroll with args and compares the transcript and the parsed gen outputs
+// with the golden file. The scroll should be created with a ScriptedLLM so the test stays offline and deterministic.
+func AssertTranscriptGolden(t testing.TB, scroll *scrolls.Scroll, args map[string]any, goldenPath string) {
+	t.Helper()
+
+	msgs, outputs, err := scroll.ExecuteParsed(context.Background(), args)
+	require.NoError(t, err)
+	assertGolden(t, Transcript{Messages: msgs, Outputs: outputs}, goldenPath)
+}
+
+func assertGolden(t testing.TB, v any, goldenPath string) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(v, "", "  ")
+	require.NoError(t, err)
+	actual = append(actual, '\n')
+
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(goldenPath), 0o755))
+		require.NoError(t, os.WriteFile(goldenPath, actual, 0o644))
+		return
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist, run the test with -scrollstest.update to create it", goldenPath)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual), "output differs from golden file %s, run the test with -scrollstest.update to update it", goldenPath)
+}
@@ -0,0 +1,87 @@
+package scrollstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dskart/gollum/scrolls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTemplate string = `
+[[#system~]]
+You are a helpful and terse assistant.
+[[~/system]]
+
+[[#user~]]
+List three {{.topic}}.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "items", "parser": {"type": "list"}}
+[[~/assistant]]
+
+[[#user~]]
+Pick your favorite.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "favorite", "temperature": 0}
+[[~/assistant]]
+`
+
+func TestAssertMessagesGolden(t *testing.T) {
+	scroll := scrolls.New(testTemplate, nil)
+	AssertMessagesGolden(t, scroll, map[string]any{"topic": "fruits"}, "testdata/messages.golden")
+}
+
+func TestAssertTranscriptGolden(t *testing.T) {
+	llm := NewScriptedLLM("- apple\n- banana\n- cherry", "cherry")
+	scroll := scrolls.New(testTemplate, llm)
+	AssertTranscriptGolden(t, scroll, map[string]any{"topic": "fruits"}, "testdata/transcript.golden")
+
+	assert.Len(t, llm.Requests(), 2)
+	assert.Zero(t, llm.Remaining())
+}
+
+var parallelTemplate string = `
+[[#user~]]
+Name a fruit.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "fruit"}
+[[~/assistant]]
+
+[[#user~]]
+Name a vegetable.
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "vegetable", "parallel": true}
+[[~/assistant]]
+`
+
+func TestMatchingLLM(t *testing.T) {
+	// the gens are prompted concurrently, so the scripts must match the requests instead of their order
+	for range 20 {
+		llm := NewMatchingLLM(
+			Script{When: LastMessageContains("vegetable"), Response: "carrot"},
+			Script{When: LastMessageContains("fruit"), Response: "apple"},
+		)
+		scroll := scrolls.New(parallelTemplate, llm)
+		_, outputs, err := scroll.Execute(context.Background(), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"fruit": "apple", "vegetable": "carrot"}, outputs)
+		assert.Zero(t, llm.Remaining())
+	}
+}
+
+func TestMatchingLLM_NoMatch(t *testing.T) {
+	llm := NewMatchingLLM(Script{When: LastMessageContains("vegetable"), Response: "carrot"})
+	scroll := scrolls.New(parallelTemplate, llm)
+	_, _, err := scroll.Execute(context.Background(), nil)
+	assert.Error(t, err)
+}
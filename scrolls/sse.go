@@ -0,0 +1,52 @@
+package scrolls
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WriteEvent writes the event to w in the server-sent events format.
+func WriteEvent(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not marshal event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// EventStreamHandler returns an http.Handler that executes the scroll for every request and
+// streams the execution events to the client as server-sent events.
+// argsFn builds the scroll args from the request, if it fails the handler responds with a 400.
+func EventStreamHandler(s *Scroll, argsFn func(*http.Request) (map[string]any, error), opts ...func(*ExecuteOptions)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args, err := argsFn(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for event := range s.ExecuteEvents(r.Context(), args, opts...) {
+			if err := WriteEvent(w, event); err != nil {
+				// the client is gone, the request context cancels the execution
+				continue
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// WithPartials makes the given named templates available to the scroll, they can be included with {{template "name" .}}.
+// Partials can also override the {{block}} defaults of a base scroll.
+func WithPartials(partials map[string]string) func(*Options) {
+	return func(opts *Options) {
+		if opts.partials == nil {
+			opts.partials = make(map[string]string, len(partials))
+		}
+		maps.Copy(opts.partials, partials)
+	}
+}
+
+// WithBase makes the scroll extend the given base scroll text. The base is rendered instead of the scroll text,
+// the scroll overrides the base {{block}} sections with {{define}} and should not contain anything else.
+func WithBase(base string) func(*Options) {
+	return func(opts *Options) {
+		opts.base = &base
+	}
+}
+
+// NewFromFile creates a scroll from the text of the given file in fsys.
+func NewFromFile(fsys fs.FS, name string, openAi openai.OpenAi, opts ...func(*Options)) (*Scroll, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scroll %s: %w", name, err)
+	}
+	return New(string(data), openAi, opts...), nil
+}
+
+// LoadPartials reads the files matching the patterns in fsys and returns them keyed by their file name without extension.
+func LoadPartials(fsys fs.FS, patterns ...string) (map[string]string, error) {
+	partials := make(map[string]string)
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, fmt.Errorf("could not read partial %s: %w", file, err)
+			}
+			name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+			if _, ok := partials[name]; ok {
+				return nil, fmt.Errorf("duplicate partial %q", name)
+			}
+			partials[name] = string(data)
+		}
+	}
+	return partials, nil
+}
+
+// template returns the parsed scroll template, it is only parsed once and then cached.
+func (s *Scroll) template() (*template.Template, error) {
+	s.mu.RLock()
+	tmpl, err := s.tmpl, s.tmplErr
+	s.mu.RUnlock()
+	if tmpl != nil || err != nil {
+		return tmpl, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmpl == nil && s.tmplErr == nil {
+		s.tmpl, s.tmplErr = s.parseTemplate()
+	}
+	return s.tmpl, s.tmplErr
+}
+
+func (s *Scroll) parseTemplate() (*template.Template, error) {
+	tmpl := template.New("scroll").Funcs(s.truncateFuncs(nil)).Funcs(s.exampleFuncs(context.Background())).Funcs(outputFuncs()).Funcs(s.funcMap)
+
+	// the base is parsed first so partials and the scroll itself can override its blocks
+	if s.base != nil {
+		if _, err := tmpl.Parse(*s.base); err != nil {
+			return nil, fmt.Errorf("could not parse base template: %w", err)
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(s.partials))
+	for _, name := range names {
+		if _, err := tmpl.New(name).Parse(s.partials[name]); err != nil {
+			return nil, fmt.Errorf("could not parse partial %q: %w", name, err)
+		}
+	}
+
+	if _, err := tmpl.Parse(s.text); err != nil {
+		return nil, fmt.Errorf("could not parse template text: %w", err)
+	}
+
+	if err := checkTemplateReferences(tmpl); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// checkTemplateReferences makes sure every included template exists and that templates don't include each other in a cycle.
+func checkTemplateReferences(tmpl *template.Template) error {
+	refs := make(map[string][]string)
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		refs[t.Name()] = templateReferences(t.Tree.Root)
+	}
+
+	for name, targets := range refs {
+		for _, target := range targets {
+			if _, ok := refs[target]; !ok {
+				return fmt.Errorf("template %q includes undefined template %q", name, target)
+			}
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int, len(refs))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("template include cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, target := range refs[name] {
+			if err := visit(target, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(refs)) {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func templateReferences(node parse.Node) []string {
+	refs := make([]string, 0)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.TemplateNode:
+			refs = append(refs, n.Name)
+		case *parse.IfNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	walk(node)
+	return refs
+}
@@ -0,0 +1,125 @@
+package scrolls
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// Tokenizer counts the tokens of a text for the model the scroll is executed with.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// approxTokenizer assumes about 4 characters per token, which is close enough for English text
+// when no model-specific tokenizer is available.
+type approxTokenizer struct{}
+
+func (approxTokenizer) CountTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+var DefaultTokenizer Tokenizer = approxTokenizer{}
+
+// messageTokenOverhead is the number of tokens used by the formatting of every chat message.
+const messageTokenOverhead = 4
+
+// CountMessageTokens counts the tokens of a chat message, including its name, tool calls and formatting overhead.
+func CountMessageTokens(tokenizer Tokenizer, msg openai.Message) int {
+	n := messageTokenOverhead
+	if msg.Content != nil {
+		n += tokenizer.CountTokens(*msg.Content)
+	}
+	if msg.Name != nil {
+		n += tokenizer.CountTokens(*msg.Name)
+	}
+	for _, toolCall := range msg.ToolCalls {
+		n += tokenizer.CountTokens(toolCall.Function.Name) + tokenizer.CountTokens(toolCall.Function.Arguments)
+	}
+	return n
+}
+
+type TruncationStrategy string
+
+const (
+	// HeadTruncationStrategy keeps the beginning of the text and cuts the end.
+	HeadTruncationStrategy TruncationStrategy = "head"
+	// TailTruncationStrategy keeps the end of the text and cuts the beginning.
+	TailTruncationStrategy TruncationStrategy = "tail"
+	// MiddleTruncationStrategy keeps both ends of the text and elides the middle.
+	MiddleTruncationStrategy TruncationStrategy = "middle"
+	// OldestTruncationStrategy drops the oldest messages or list items first, it behaves like tail on texts.
+	OldestTruncationStrategy TruncationStrategy = "oldest"
+)
+
+// elision marks where a text was cut.
+const elision = "…"
+
+func (t TruncationStrategy) validate() error {
+	switch t {
+	case HeadTruncationStrategy, TailTruncationStrategy, MiddleTruncationStrategy, OldestTruncationStrategy:
+		return nil
+	default:
+		return fmt.Errorf("unknown truncation strategy %q", t)
+	}
+}
+
+// truncateText cuts text down to maxTokens following the strategy. It returns whether the text was cut.
+func truncateText(tokenizer Tokenizer, text string, maxTokens int, strategy TruncationStrategy) (string, bool) {
+	if tokenizer.CountTokens(text) <= maxTokens {
+		return text, false
+	}
+	if maxTokens <= 0 {
+		return "", true
+	}
+
+	runes := []rune(text)
+	keep := func(k int) string {
+		switch strategy {
+		case HeadTruncationStrategy:
+			return string(runes[:k]) + elision
+		case MiddleTruncationStrategy:
+			head := (k + 1) / 2
+			return string(runes[:head]) + elision + string(runes[len(runes)-(k-head):])
+		default:
+			return elision + string(runes[len(runes)-k:])
+		}
+	}
+
+	// find the largest number of runes to keep that fits in maxTokens
+	k := sort.Search(len(runes)+1, func(k int) bool {
+		return tokenizer.CountTokens(keep(k)) > maxTokens
+	}) - 1
+	if k <= 0 {
+		return "", true
+	}
+	return keep(k), true
+}
+
+// truncateItems drops items until the kept ones fit in maxTokens. It returns the kept items and how many were dropped.
+func truncateItems[T any](items []T, count func(T) int, maxTokens int, strategy TruncationStrategy) ([]T, int) {
+	total := 0
+	for _, item := range items {
+		total += count(item)
+	}
+
+	kept := slices.Clone(items)
+	for total > maxTokens && len(kept) > 0 {
+		var i int
+		switch strategy {
+		case HeadTruncationStrategy:
+			i = len(kept) - 1
+		case MiddleTruncationStrategy:
+			i = len(kept) / 2
+		default:
+			i = 0
+		}
+		total -= count(kept[i])
+		kept = slices.Delete(kept, i, i+1)
+	}
+
+	return kept, len(items) - len(kept)
+}
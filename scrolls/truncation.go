@@ -0,0 +1,345 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"text/template"
+
+	"github.com/dskart/gollum/openai"
+)
+
+// TruncationPolicy limits the number of tokens of a scroll arg before the scroll is rendered.
+// Args can be strings, []string or []openai.Message, lists are truncated item by item.
+// A MaxTokens of 0 means the arg is only truncated when the scroll exceeds its token budget.
+type TruncationPolicy struct {
+	MaxTokens int
+	Strategy  TruncationStrategy
+}
+
+// Truncation reports what was cut while rendering a scroll.
+type Truncation struct {
+	// Target is the name of the truncated arg, the template function that truncated a text, or "messages"
+	// when rendered messages were dropped to fit the token budget.
+	Target         string             `json:"target"`
+	Strategy       TruncationStrategy `json:"strategy"`
+	OriginalTokens int                `json:"original_tokens"`
+	Tokens         int                `json:"tokens"`
+	DroppedItems   int                `json:"dropped_items,omitempty"`
+}
+
+type tokenBudget struct {
+	maxTokens                int
+	reservedCompletionTokens int
+}
+
+// WithTokenizer sets the tokenizer used to count tokens, it defaults to DefaultTokenizer.
+func WithTokenizer(tokenizer Tokenizer) func(*Options) {
+	return func(opts *Options) {
+		opts.tokenizer = tokenizer
+	}
+}
+
+// WithTruncationPolicy truncates the given arg according to the policy every time the scroll is rendered.
+func WithTruncationPolicy(arg string, policy TruncationPolicy) func(*Options) {
+	return func(opts *Options) {
+		if opts.truncationPolicies == nil {
+			opts.truncationPolicies = make(map[string]TruncationPolicy)
+		}
+		opts.truncationPolicies[arg] = policy
+	}
+}
+
+// WithTokenBudget makes the rendered messages fit in maxTokens minus the tokens reserved for the completion.
+// When the scroll is over budget, the largest arg with a truncation policy is truncated further, then the
+// oldest messages before the first gen action are dropped. If it still doesn't fit, rendering fails with
+// ErrTokenBudgetExceeded.
+func WithTokenBudget(maxTokens int, reservedCompletionTokens int) func(*Options) {
+	return func(opts *Options) {
+		opts.tokenBudget = &tokenBudget{
+			maxTokens:                maxTokens,
+			reservedCompletionTokens: reservedCompletionTokens,
+		}
+	}
+}
+
+// maxBudgetAttempts bounds the number of times a scroll is re-rendered to fit its token budget.
+const maxBudgetAttempts = 16
+
+// Render renders the scroll with args and returns its messages along with what was truncated to render it.
+func (s *Scroll) Render(args map[string]any) ([]openai.Message, []Truncation, error) {
+	parsed, err := s.parse(context.Background(), args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parsed.msgs, parsed.truncations, nil
+}
+
+func (s *Scroll) parse(ctx context.Context, args map[string]any) (parsedScroll, error) {
+	tmpl, err := s.template()
+	if err != nil {
+		return parsedScroll{}, err
+	}
+
+	limits := make(map[string]int, len(s.truncationPolicies))
+	for arg, policy := range s.truncationPolicies {
+		if err := policy.Strategy.validate(); err != nil {
+			return parsedScroll{}, fmt.Errorf("invalid truncation policy for %s: %w", arg, err)
+		}
+		if policy.MaxTokens > 0 {
+			limits[arg] = policy.MaxTokens
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		truncatedArgs, argTokens, truncations, err := s.truncateArgs(args, limits)
+		if err != nil {
+			return parsedScroll{}, err
+		}
+
+		parsed, err := s.render(ctx, tmpl, truncatedArgs)
+		if err != nil {
+			return parsedScroll{}, err
+		}
+		parsed.truncations = append(truncations, parsed.truncations...)
+
+		if s.tokenBudget == nil {
+			return parsed, nil
+		}
+		overflow := s.promptTokens(parsed.msgs) - s.availableTokens()
+		if overflow <= 0 {
+			return parsed, nil
+		}
+
+		// shrink the largest arg that can still be truncated and render again
+		if attempt < maxBudgetAttempts {
+			arg, tokens := largestArg(argTokens)
+			if tokens > 0 {
+				limits[arg] = max(0, tokens-overflow)
+				continue
+			}
+		}
+
+		return s.dropOldestMessages(parsed, overflow)
+	}
+}
+
+func (s *Scroll) availableTokens() int {
+	return s.tokenBudget.maxTokens - s.tokenBudget.reservedCompletionTokens
+}
+
+// promptTokens counts the tokens of the messages sent to the llm, gen actions are replaced by their output so they don't count.
+func (s *Scroll) promptTokens(msgs []openai.Message) int {
+	n := 0
+	for _, msg := range msgs {
+		if _, ok := parseGenAction(msg); ok {
+			continue
+		}
+		n += CountMessageTokens(s.tokenizer, msg)
+	}
+	return n
+}
+
+func largestArg(argTokens map[string]int) (string, int) {
+	largest, largestTokens := "", 0
+	for _, arg := range slices.Sorted(maps.Keys(argTokens)) {
+		if argTokens[arg] > largestTokens {
+			largest, largestTokens = arg, argTokens[arg]
+		}
+	}
+	return largest, largestTokens
+}
+
+// truncateArgs applies the truncation policies to a copy of args. It returns the truncated args
+// and the number of tokens of every arg that has a policy.
+func (s *Scroll) truncateArgs(args map[string]any, limits map[string]int) (map[string]any, map[string]int, []Truncation, error) {
+	if len(s.truncationPolicies) == 0 {
+		return args, nil, nil, nil
+	}
+
+	truncatedArgs := maps.Clone(args)
+	argTokens := make(map[string]int, len(s.truncationPolicies))
+	truncations := make([]Truncation, 0)
+	for _, arg := range slices.Sorted(maps.Keys(s.truncationPolicies)) {
+		value, ok := args[arg]
+		if !ok {
+			continue
+		}
+
+		strategy := s.truncationPolicies[arg].Strategy
+		limit, ok := limits[arg]
+		if !ok {
+			tokens, err := s.valueTokens(value)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("could not truncate %s: %w", arg, err)
+			}
+			argTokens[arg] = tokens
+			continue
+		}
+
+		truncated, truncation, err := s.truncateValue(value, limit, strategy)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("could not truncate %s: %w", arg, err)
+		}
+		argTokens[arg] = truncation.Tokens
+		if truncation.Tokens < truncation.OriginalTokens {
+			truncation.Target = arg
+			truncations = append(truncations, truncation)
+			truncatedArgs[arg] = truncated
+		}
+	}
+
+	return truncatedArgs, argTokens, truncations, nil
+}
+
+func (s *Scroll) valueTokens(value any) (int, error) {
+	switch v := value.(type) {
+	case string:
+		return s.tokenizer.CountTokens(v), nil
+	case []string:
+		n := 0
+		for _, item := range v {
+			n += s.tokenizer.CountTokens(item)
+		}
+		return n, nil
+	case []openai.Message:
+		n := 0
+		for _, msg := range v {
+			n += CountMessageTokens(s.tokenizer, msg)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func (s *Scroll) truncateValue(value any, maxTokens int, strategy TruncationStrategy) (any, Truncation, error) {
+	originalTokens, err := s.valueTokens(value)
+	if err != nil {
+		return nil, Truncation{}, err
+	}
+
+	truncation := Truncation{Strategy: strategy, OriginalTokens: originalTokens}
+	var truncated any
+	switch v := value.(type) {
+	case string:
+		truncated, _ = truncateText(s.tokenizer, v, maxTokens, strategy)
+	case []string:
+		truncated, truncation.DroppedItems = truncateItems(v, s.tokenizer.CountTokens, maxTokens, strategy)
+	case []openai.Message:
+		truncated, truncation.DroppedItems = truncateItems(v, func(msg openai.Message) int {
+			return CountMessageTokens(s.tokenizer, msg)
+		}, maxTokens, strategy)
+	}
+
+	truncation.Tokens, err = s.valueTokens(truncated)
+	if err != nil {
+		return nil, Truncation{}, err
+	}
+	return truncated, truncation, nil
+}
+
+// dropOldestMessages drops the oldest non system messages rendered before the first gen action or directive until the scroll fits its budget.
+func (s *Scroll) dropOldestMessages(parsed parsedScroll, overflow int) (parsedScroll, error) {
+	truncation := Truncation{
+		Target:         "messages",
+		Strategy:       OldestTruncationStrategy,
+		OriginalTokens: s.promptTokens(parsed.msgs),
+	}
+
+	end := len(parsed.msgs)
+	if len(parsed.directives) > 0 {
+		end = parsed.directives[0].index
+	}
+
+	msgs := slices.Clone(parsed.msgs)
+	for i := 0; overflow > 0 && i < end; {
+		msg := msgs[i]
+		if _, ok := parseGenAction(msg); ok {
+			break
+		}
+		if msg.Role == openai.SystemRoleType {
+			i++
+			continue
+		}
+		overflow -= CountMessageTokens(s.tokenizer, msg)
+		msgs = slices.Delete(msgs, i, i+1)
+		end--
+		truncation.DroppedItems++
+	}
+
+	if overflow > 0 {
+		return parsedScroll{}, fmt.Errorf("%w: %d tokens over the %d available", ErrTokenBudgetExceeded, overflow, s.availableTokens())
+	}
+
+	// every directive comes after the dropped messages
+	directives := slices.Clone(parsed.directives)
+	for i := range directives {
+		directives[i].index -= truncation.DroppedItems
+	}
+
+	truncation.Tokens = s.promptTokens(msgs)
+	parsed.msgs = msgs
+	parsed.directives = directives
+	parsed.truncations = append(parsed.truncations, truncation)
+	return parsed, nil
+}
+
+// truncateFuncs are the template functions to count and truncate tokens.
+// Truncations made by truncate_tokens are reported through record when it is set.
+func (s *Scroll) truncateFuncs(record func(Truncation)) template.FuncMap {
+	return template.FuncMap{
+		"count_tokens": func(text string) int {
+			return s.tokenizer.CountTokens(text)
+		},
+		// truncate_tokens truncates text to maxTokens, the strategy defaults to head
+		"truncate_tokens": func(text string, maxTokens int, strategy ...string) (string, error) {
+			truncationStrategy := HeadTruncationStrategy
+			if len(strategy) > 0 {
+				truncationStrategy = TruncationStrategy(strategy[0])
+			}
+			if err := truncationStrategy.validate(); err != nil {
+				return "", err
+			}
+
+			truncated, ok := truncateText(s.tokenizer, text, maxTokens, truncationStrategy)
+			if ok && record != nil {
+				record(Truncation{
+					Target:         "truncate_tokens",
+					Strategy:       truncationStrategy,
+					OriginalTokens: s.tokenizer.CountTokens(text),
+					Tokens:         s.tokenizer.CountTokens(truncated),
+				})
+			}
+			return truncated, nil
+		},
+	}
+}
+
+// renderFuncs returns the template functions bound to a single render so their truncations can be reported
+// and examples are selected with the context of the render.
+func (s *Scroll) renderFuncs(ctx context.Context) (template.FuncMap, func() []Truncation) {
+	var mu sync.Mutex
+	truncations := make([]Truncation, 0)
+	funcs := s.truncateFuncs(func(t Truncation) {
+		mu.Lock()
+		defer mu.Unlock()
+		truncations = append(truncations, t)
+	})
+	maps.Copy(funcs, s.exampleFuncs(ctx))
+
+	// functions overridden by the user func map are left alone
+	for name := range funcs {
+		if _, ok := s.funcMap[name]; ok {
+			delete(funcs, name)
+		}
+	}
+
+	return funcs, func() []Truncation {
+		mu.Lock()
+		defer mu.Unlock()
+		return truncations
+	}
+}
@@ -0,0 +1,133 @@
+package scrolls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dskart/gollum/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateText(t *testing.T) {
+	text := "0123456789abcdefghijklmnopqrstuvwxyzABCD"
+	require.Equal(t, 10, DefaultTokenizer.CountTokens(text))
+
+	testCases := []struct {
+		strategy TruncationStrategy
+		expected string
+	}{
+		{strategy: HeadTruncationStrategy, expected: "0123456789abcdefghi…"},
+		{strategy: TailTruncationStrategy, expected: "…lmnopqrstuvwxyzABCD"},
+		{strategy: OldestTruncationStrategy, expected: "…lmnopqrstuvwxyzABCD"},
+		{strategy: MiddleTruncationStrategy, expected: "0123456789…vwxyzABCD"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			truncated, ok := truncateText(DefaultTokenizer, text, 5, tc.strategy)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, truncated)
+			assert.LessOrEqual(t, DefaultTokenizer.CountTokens(truncated), 5)
+		})
+	}
+
+	truncated, ok := truncateText(DefaultTokenizer, text, 10, HeadTruncationStrategy)
+	assert.False(t, ok)
+	assert.Equal(t, text, truncated)
+}
+
+func TestScroll_Truncation(t *testing.T) {
+	document := strings.Repeat("word ", 100)
+
+	t.Run("TemplateFunc", func(t *testing.T) {
+		scroll := New(`[[#user~]]{{truncate_tokens .document 10 "tail"}}[[~/user]]`, nil)
+
+		msgs, truncations, err := scroll.Render(map[string]any{"document": document})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, DefaultTokenizer.CountTokens(*msgs[0].Content), 10)
+		assert.Equal(t, []Truncation{
+			{Target: "truncate_tokens", Strategy: TailTruncationStrategy, OriginalTokens: 125, Tokens: 10},
+		}, truncations)
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		history := []openai.Message{
+			{Role: openai.UserRoleType, Content: toPointer(strings.Repeat("a", 40))},
+			{Role: openai.AssistantRoleType, Content: toPointer(strings.Repeat("b", 40))},
+			{Role: openai.UserRoleType, Content: toPointer(strings.Repeat("c", 40))},
+		}
+		scroll := New(`[[#user~]]{{range .history}}{{.Content}}{{end}}[[~/user]]`, nil, WithTruncationPolicy("history", TruncationPolicy{
+			MaxTokens: 30,
+			Strategy:  OldestTruncationStrategy,
+		}))
+
+		msgs, truncations, err := scroll.Render(map[string]any{"history": history})
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("b", 40)+strings.Repeat("c", 40), *msgs[0].Content)
+		assert.Equal(t, []Truncation{
+			{Target: "history", Strategy: OldestTruncationStrategy, OriginalTokens: 42, Tokens: 28, DroppedItems: 1},
+		}, truncations)
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		template := `
+[[#system~]]
+You summarize documents.
+[[~/system]]
+
+[[#user~]]
+{{.document}}
+[[~/user]]
+
+[[#assistant~]]
+{"action": "gen", "output_name": "summary"}
+[[~/assistant]]
+`
+		scroll := New(template, nil,
+			WithTruncationPolicy("document", TruncationPolicy{Strategy: MiddleTruncationStrategy}),
+			WithTokenBudget(100, 40),
+		)
+
+		msgs, truncations, err := scroll.Render(map[string]any{"document": document})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, scroll.promptTokens(msgs), 60)
+		require.Len(t, truncations, 1)
+		assert.Equal(t, "document", truncations[0].Target)
+		assert.Equal(t, MiddleTruncationStrategy, truncations[0].Strategy)
+		assert.Contains(t, *msgs[1].Content, elision)
+	})
+
+	t.Run("BudgetDropsOldestMessages", func(t *testing.T) {
+		template := `
+[[#system~]]
+You are a chat assistant.
+[[~/system]]
+{{range .turns}}
+[[#user~]]
+{{.}}
+[[~/user]]
+{{end}}
+[[#assistant~]]
+{"action": "gen", "output_name": "reply"}
+[[~/assistant]]
+`
+		turns := []string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}
+		scroll := New(template, nil, WithTokenBudget(60, 20))
+
+		msgs, truncations, err := scroll.Render(map[string]any{"turns": turns})
+		require.NoError(t, err)
+		require.Len(t, msgs, 4)
+		assert.Equal(t, openai.SystemRoleType, msgs[0].Role)
+		assert.Equal(t, strings.Repeat("b", 40), *msgs[1].Content)
+		require.Len(t, truncations, 1)
+		assert.Equal(t, "messages", truncations[0].Target)
+		assert.Equal(t, 1, truncations[0].DroppedItems)
+	})
+
+	t.Run("BudgetExceeded", func(t *testing.T) {
+		scroll := New(`[[#system~]]{{.document}}[[~/system]]`, nil, WithTokenBudget(50, 20))
+
+		_, _, err := scroll.Render(map[string]any{"document": document})
+		require.ErrorIs(t, err, ErrTokenBudgetExceeded)
+	})
+}
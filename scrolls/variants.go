@@ -0,0 +1,105 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// Variant is one version of a prompt in a ScrollSet experiment.
+type Variant struct {
+	Id     string
+	Weight int
+	Scroll *Scroll
+}
+
+// ScrollSet holds weighted variants of the same prompt for A/B testing.
+// Assignments are sticky: the same key always gets the same variant as long as the variants don't change.
+type ScrollSet struct {
+	name        string
+	variants    []Variant
+	totalWeight int
+}
+
+// VariantResult is the result of a ScrollSet execution, tagged with the variant that was executed.
+type VariantResult struct {
+	VariantId string
+	Result
+}
+
+func NewScrollSet(name string, variants ...Variant) (*ScrollSet, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("scroll set %s: no variants", name)
+	}
+
+	ids := make(map[string]struct{}, len(variants))
+	totalWeight := 0
+	for _, variant := range variants {
+		if variant.Id == "" {
+			return nil, fmt.Errorf("scroll set %s: variant without id", name)
+		}
+		if _, ok := ids[variant.Id]; ok {
+			return nil, fmt.Errorf("scroll set %s: duplicate variant %q", name, variant.Id)
+		}
+		ids[variant.Id] = struct{}{}
+
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("scroll set %s: variant %q has a negative weight", name, variant.Id)
+		}
+		if variant.Scroll == nil {
+			return nil, fmt.Errorf("scroll set %s: variant %q has no scroll", name, variant.Id)
+		}
+		totalWeight += variant.Weight
+	}
+	if totalWeight == 0 {
+		return nil, fmt.Errorf("scroll set %s: variants have no weight", name)
+	}
+
+	return &ScrollSet{
+		name:        name,
+		variants:    variants,
+		totalWeight: totalWeight,
+	}, nil
+}
+
+func (s *ScrollSet) Name() string {
+	return s.name
+}
+
+// Assign returns the variant of the given user or session key.
+func (s *ScrollSet) Assign(key string) Variant {
+	h := fnv.New64a()
+	// the set name is hashed too so keys are assigned independently across experiments
+	_, _ = h.Write([]byte(s.name + "\x00" + key))
+	point := int(h.Sum64() % uint64(s.totalWeight))
+
+	for _, variant := range s.variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return s.variants[len(s.variants)-1]
+}
+
+// Variant returns the variant with the given id.
+func (s *ScrollSet) Variant(id string) (Variant, bool) {
+	for _, variant := range s.variants {
+		if variant.Id == id {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// Execute executes the variant assigned to key. Every event emitted during the execution carries the variant id.
+func (s *ScrollSet) Execute(ctx context.Context, key string, args map[string]any, opts ...func(*ExecuteOptions)) (VariantResult, error) {
+	variant := s.Assign(key)
+	opts = append(opts, withVariantId(variant.Id))
+
+	res, err := variant.Scroll.ExecuteResult(ctx, args, opts...)
+	if err != nil {
+		return VariantResult{VariantId: variant.Id, Result: res}, fmt.Errorf("variant %s: %w", variant.Id, err)
+	}
+	return VariantResult{VariantId: variant.Id, Result: res}, nil
+}
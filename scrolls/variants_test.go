@@ -0,0 +1,65 @@
+package scrolls
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrollSet(t *testing.T) {
+	ctx := context.Background()
+
+	terse := New(testTemplate, &scriptedLLM{responses: []string{"42"}})
+	verbose := New(testTemplate, &scriptedLLM{responses: []string{"The answer is 42"}})
+
+	set, err := NewScrollSet("answer_style",
+		Variant{Id: "terse", Weight: 3, Scroll: terse},
+		Variant{Id: "verbose", Weight: 1, Scroll: verbose},
+	)
+	require.NoError(t, err)
+
+	t.Run("Assign", func(t *testing.T) {
+		counts := make(map[string]int)
+		for i := range 4000 {
+			key := fmt.Sprintf("user-%d", i)
+			variant := set.Assign(key)
+			assert.Equal(t, variant.Id, set.Assign(key).Id)
+			counts[variant.Id]++
+		}
+		assert.InDelta(t, 3000, counts["terse"], 200)
+		assert.InDelta(t, 1000, counts["verbose"], 200)
+	})
+
+	t.Run("Execute", func(t *testing.T) {
+		key := "user-1"
+		expected := set.Assign(key)
+
+		var events []Event
+		res, err := set.Execute(ctx, key, map[string]any{"query": "What is the meaning of life?"}, WithEventHandler(func(e Event) {
+			events = append(events, e)
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.Id, res.VariantId)
+		assert.Len(t, res.Messages, 3)
+		assert.Contains(t, res.Outputs, "response")
+		require.NotEmpty(t, events)
+		for _, event := range events {
+			assert.Equal(t, expected.Id, event.VariantId)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewScrollSet("empty")
+		require.Error(t, err)
+
+		_, err = NewScrollSet("duplicate", Variant{Id: "a", Weight: 1, Scroll: terse}, Variant{Id: "a", Weight: 1, Scroll: verbose})
+		require.Error(t, err)
+
+		_, err = NewScrollSet("no_weight", Variant{Id: "a", Scroll: terse})
+		require.Error(t, err)
+	})
+}